@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	cloudcontrollermanager "github.com/crusoecloud/crusoe-cloud-controller-manager/internal"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instancegroup"
 	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/node"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/tracing"
 	"k8s.io/apimachinery/pkg/util/wait"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/cloud-provider/app"
@@ -30,28 +33,57 @@ func main() {
 	opts.KubeCloudShared.CloudProvider.Name = ProviderName
 	opts.Authentication.SkipInClusterLookup = true
 
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up tracing: %v\n", err)
+		os.Exit(1)
+	}
+
 	cloudcontrollermanager.RegisterCloudProvider()
 
+	nodeOpts := node.NewOptions()
+	fss := flag.NamedFlagSets{}
+	nodeOpts.AddFlags(fss.FlagSet("crusoe node lifecycle controller"))
+	instanceGroupOpts := instancegroup.NewOptions()
+	instanceGroupOpts.AddFlags(fss.FlagSet("crusoe instance group controller"))
+
 	// Set the default CloudNodeLifecycleController to our custom implementation
 	app.DefaultInitFuncConstructors[names.CloudNodeLifecycleController] = app.ControllerInitFuncConstructor{
 		InitContext: app.ControllerInitContext{
 			ClientName: "node-controller",
 		},
-		Constructor: node.StartCloudNodeLifecycleControllerWrapper,
+		Constructor: nodeOpts.StartCloudNodeLifecycleControllerWrapper,
 	}
+	// The instance group controller lists every instance group of the project each sync
+	// period and writes a summary ConfigMap to kube-system. Existing deployments did neither,
+	// so it is opt-in with --controllers=*,crusoe-instance-group.
+	app.DefaultInitFuncConstructors[instancegroup.ControllerName] = app.ControllerInitFuncConstructor{
+		InitContext: app.ControllerInitContext{
+			ClientName: "instance-group-controller",
+		},
+		Constructor: instanceGroupOpts.StartInstanceGroupControllerWrapper,
+	}
+	app.ControllersDisabledByDefault.Insert(instancegroup.ControllerName)
 
+	// No node-ipam controller is registered. Crusoe network interfaces only carry a single
+	// private and public IPv4 address, the API has no secondary ranges or alias IP blocks
+	// to allocate pod CIDRs from, so pod CIDRs are left to kube-controller-manager.
 	command := app.NewCloudControllerManagerCommand(
 		opts,
 		doInitializer,
 		app.DefaultInitFuncConstructors,
 		map[string]string{},
-		flag.NamedFlagSets{},
+		fss,
 		wait.NeverStop,
 	)
 
 	logs.InitLogs()
 
-	if err := command.Execute(); err != nil {
+	err = command.Execute()
+	if shutdownErr := shutdownTracing(context.Background()); shutdownErr != nil {
+		klog.ErrorS(shutdownErr, "Failed to flush traces")
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		logs.FlushLogs() // Ensure logs are flushed before exiting
 		os.Exit(1)
@@ -68,6 +100,12 @@ func doInitializer(cfg *config.CompletedConfig) cloudprovider.Interface {
 	if cloud == nil {
 		klog.Fatalf("Cloud provider is nil")
 	}
+	// unlike the sample, a missing cluster ID is not fatal so that clusters deployed
+	// without a cluster config keep working
+	if !cloud.HasClusterID() {
+		klog.InfoS("No cluster ID is configured, set cluster.id or cluster.name in the cloud config " +
+			"to label the nodes of this cluster with its ID")
+	}
 
 	return cloud
 }
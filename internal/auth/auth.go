@@ -21,7 +21,11 @@ const (
 	authVersion     = "1.0"
 )
 
-var errSemicolonSeparator = errors.New("invalid semicolon separator in query")
+var (
+	// ErrSignRequest is returned by the AuthenticatingTransport when a request cannot be signed.
+	ErrSignRequest        = errors.New("failed to sign request")
+	errSemicolonSeparator = errors.New("invalid semicolon separator in query")
+)
 
 // AuthenticatingTransport is a struct implementing http.Roundtripper
 // that authenticates a request to Crusoe Cloud before sending it out.
@@ -45,7 +49,7 @@ func NewAuthenticatingTransport(r http.RoundTripper, keyID, secretKey string) Au
 
 func (t AuthenticatingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 	if err := addSignature(r, t.keyID, t.secretKey); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrSignRequest, err)
 	}
 
 	//nolint:wrapcheck // error should be forwarded here.
@@ -183,7 +187,10 @@ func encodeQuery(values map[string][]string) string {
 }
 
 // NewCrusoeClient initializes a new Crusoe API client with the given configuration.
-func NewCrusoeClient(host, key, secret, userAgent string) *crusoeapi.APIClient {
+// The wrappers are applied around the authenticating transport, in order.
+func NewCrusoeClient(host, key, secret, userAgent string,
+	wrappers ...func(http.RoundTripper) http.RoundTripper,
+) *crusoeapi.APIClient {
 	cfg := crusoeapi.NewConfiguration()
 	cfg.UserAgent = userAgent
 	cfg.BasePath = host
@@ -191,7 +198,11 @@ func NewCrusoeClient(host, key, secret, userAgent string) *crusoeapi.APIClient {
 		cfg.HTTPClient = http.DefaultClient
 	}
 
-	cfg.HTTPClient.Transport = NewAuthenticatingTransport(cfg.HTTPClient.Transport, key, secret)
+	var transport http.RoundTripper = NewAuthenticatingTransport(cfg.HTTPClient.Transport, key, secret)
+	for _, wrap := range wrappers {
+		transport = wrap(transport)
+	}
+	cfg.HTTPClient.Transport = transport
 
 	return crusoeapi.NewAPIClient(cfg)
 }
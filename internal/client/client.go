@@ -18,8 +18,20 @@ const (
 )
 
 var (
-	ErrInstanceNotFound = errors.New("instance not found")
-	ErrProjectIDNotSet  = errors.New("CRUSOE_PROJECT_ID environment variable is not set")
+	// ErrNotFound is wrapped by the errors of all resources that are not found.
+	ErrNotFound                 = errors.New("not found")
+	ErrInstanceNotFound         = fmt.Errorf("instance %w", ErrNotFound)
+	ErrVMTypeNotFound           = fmt.Errorf("vm type %w", ErrNotFound)
+	ErrDiskNotFound             = fmt.Errorf("disk %w", ErrNotFound)
+	ErrClusterNotFound          = fmt.Errorf("kubernetes cluster %w", ErrNotFound)
+	ErrInstanceGroupNotFound    = fmt.Errorf("instance group %w", ErrNotFound)
+	ErrInstanceTemplateNotFound = fmt.Errorf("instance template %w", ErrNotFound)
+	// ErrZeroDesiredCount is returned when an instance group would be resized to zero,
+	// which the API client cannot send because the zero count is omitted from the request.
+	ErrZeroDesiredCount = errors.New("instance groups cannot be resized to zero instances")
+	// ErrAmbiguousInstanceName is returned when more than one instance matches a node name.
+	ErrAmbiguousInstanceName = errors.New("more than one instance matches the name")
+	ErrProjectIDNotSet       = errors.New("CRUSOE_PROJECT_ID environment variable is not set")
 )
 
 type APIClientImpl struct {
@@ -30,6 +42,15 @@ type APIClient interface {
 	GetInstanceByName(ctx context.Context, nodeName string) (*crusoeapi.InstanceV1Alpha5, error)
 	GetIBNetwork(ctx context.Context, projectID, ibPartitionID string) (*crusoeapi.IbPartition, error)
 	GetInstanceByID(ctx context.Context, instanceID string) (*crusoeapi.InstanceV1Alpha5, *http.Response, error)
+	ListInstances(ctx context.Context) ([]crusoeapi.InstanceV1Alpha5, error)
+	GetVMType(ctx context.Context, projectID, productName string) (*crusoeapi.ModelType, error)
+	GetDisk(ctx context.Context, diskID string) (*crusoeapi.DiskV1Alpha5, error)
+	ListClusters(ctx context.Context) ([]crusoeapi.KubernetesCluster, error)
+	ListInstanceGroups(ctx context.Context) ([]crusoeapi.InstanceGroup, error)
+	SetInstanceGroupDesiredCount(ctx context.Context, instanceGroupID string, desiredCount int64,
+	) (*crusoeapi.InstanceGroup, error)
+	GetInstanceTemplate(ctx context.Context, instanceTemplateID string) (*crusoeapi.InstanceTemplate, error)
+	DeleteInstance(ctx context.Context, instanceID string) error
 }
 
 func (a *APIClientImpl) GetInstanceByName(ctx context.Context, nodeName string,
@@ -43,7 +64,8 @@ func (a *APIClientImpl) GetInstanceByName(ctx context.Context, nodeName string,
 	listVMOpts := &crusoeapi.VMsApiListInstancesOpts{
 		Names: optional.NewString(instanceName),
 	}
-	instances, instancesHTTPResp, instancesErr := a.CrusoeAPIClient.VMsApi.ListInstances(ctx, projectID, listVMOpts)
+	instances, instancesHTTPResp, instancesErr := a.CrusoeAPIClient.VMsApi.ListInstances(
+		WithOperation(ctx, "ListInstances"), projectID, listVMOpts)
 	if instancesHTTPResp != nil {
 		defer instancesHTTPResp.Body.Close()
 	}
@@ -51,25 +73,50 @@ func (a *APIClientImpl) GetInstanceByName(ctx context.Context, nodeName string,
 		return nil, fmt.Errorf("failed to list instances: %w", instancesErr)
 	}
 
-	if len(instances.Items) == 0 {
-		return nil, ErrInstanceNotFound
+	return matchInstanceName(instances.Items, instanceName)
+}
+
+// matchInstanceName picks the instance named instanceName from the instances returned
+// by a name filtered list. Guessing between several instances could give a node the
+// identity of another instance, so an ambiguous match is an error.
+func matchInstanceName(instances []crusoeapi.InstanceV1Alpha5, instanceName string,
+) (*crusoeapi.InstanceV1Alpha5, error) {
+	var match *crusoeapi.InstanceV1Alpha5
+	for index := range instances {
+		if instances[index].Name != instanceName {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("%w %s: instances %s and %s", ErrAmbiguousInstanceName, instanceName,
+				match.Id, instances[index].Id)
+		}
+		match = &instances[index]
+	}
+	if match != nil {
+		return match, nil
 	}
-	klog.Infof("getInstancebyName: %v", instances.Items[0])
 
-	return &instances.Items[0], nil
+	switch len(instances) {
+	case 0:
+		return nil, ErrInstanceNotFound
+	case 1:
+		return &instances[0], nil
+	default:
+		return nil, fmt.Errorf("%w %s: %d instances", ErrAmbiguousInstanceName, instanceName, len(instances))
+	}
 }
 
 func (a *APIClientImpl) GetIBNetwork(ctx context.Context,
 	projectID, ibPartitionID string,
 ) (*crusoeapi.IbPartition, error) {
-	ibPartition, response, err := a.CrusoeAPIClient.IBPartitionsApi.GetIBPartition(ctx, projectID, ibPartitionID)
+	ibPartition, response, err := a.CrusoeAPIClient.IBPartitionsApi.GetIBPartition(
+		WithOperation(ctx, "GetIBPartition"), projectID, ibPartitionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list instances: %w", err)
 	}
 	if response != nil {
 		defer response.Body.Close()
 	}
-	klog.Infof("getIBNetwork: %v", ibPartition)
 
 	return &ibPartition, nil
 }
@@ -82,21 +129,193 @@ func (a *APIClientImpl) GetInstanceByID(ctx context.Context,
 		return nil, nil, ErrProjectIDNotSet
 	}
 
-	klog.Infof("getInstanceByID: %s", instanceID)
+	klog.V(5).InfoS("Listing instances", "operation", "ListInstances", "instanceID", instanceID)
 	listVMOpts := &crusoeapi.VMsApiListInstancesOpts{
 		Ids: optional.NewString(instanceID),
 	}
-	instances, response, err := a.CrusoeAPIClient.VMsApi.ListInstances(ctx, projectID, listVMOpts)
+	instances, response, err := a.CrusoeAPIClient.VMsApi.ListInstances(
+		WithOperation(ctx, "ListInstances"), projectID, listVMOpts)
 	if err != nil {
 		return nil, response, fmt.Errorf("failed to list instances: %w", err)
 	}
 	if response != nil {
 		defer response.Body.Close()
 	}
-	klog.Infof("getInstanceByID: %v", instances)
 	if len(instances.Items) == 0 {
 		return nil, nil, ErrInstanceNotFound
 	}
 
 	return &instances.Items[0], response, nil
 }
+
+func (a *APIClientImpl) GetVMType(ctx context.Context,
+	projectID, productName string,
+) (*crusoeapi.ModelType, error) {
+	vmTypes, response, err := a.CrusoeAPIClient.VMsApi.GetVMTypes(WithOperation(ctx, "GetVMTypes"), projectID)
+	if response != nil {
+		defer response.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vm types: %w", err)
+	}
+	for index := range vmTypes.Items {
+		if vmTypes.Items[index].ProductName == productName {
+			return &vmTypes.Items[index], nil
+		}
+	}
+
+	return nil, ErrVMTypeNotFound
+}
+
+func (a *APIClientImpl) GetDisk(ctx context.Context, diskID string) (*crusoeapi.DiskV1Alpha5, error) {
+	projectID := os.Getenv(CrusoeProjectID)
+	if projectID == "" {
+		return nil, ErrProjectIDNotSet
+	}
+
+	disk, response, err := a.CrusoeAPIClient.DisksApi.GetDisk(WithOperation(ctx, "GetDisk"), projectID, diskID)
+	if response != nil {
+		defer response.Body.Close()
+	}
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return nil, ErrDiskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk: %w", err)
+	}
+
+	return &disk, nil
+}
+
+func (a *APIClientImpl) ListClusters(ctx context.Context) ([]crusoeapi.KubernetesCluster, error) {
+	projectID := os.Getenv(CrusoeProjectID)
+	if projectID == "" {
+		return nil, ErrProjectIDNotSet
+	}
+
+	clusters, response, err := a.CrusoeAPIClient.KubernetesClustersApi.ListClusters(
+		WithOperation(ctx, "ListClusters"), projectID, nil)
+	if response != nil {
+		defer response.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list kubernetes clusters: %w", err)
+	}
+
+	return clusters.Items, nil
+}
+
+// ListInstances returns every instance of the project, following the pages of the listing.
+func (a *APIClientImpl) ListInstances(ctx context.Context) ([]crusoeapi.InstanceV1Alpha5, error) {
+	projectID := os.Getenv(CrusoeProjectID)
+	if projectID == "" {
+		return nil, ErrProjectIDNotSet
+	}
+
+	var instances []crusoeapi.InstanceV1Alpha5
+	opts := &crusoeapi.VMsApiListInstancesOpts{}
+	for {
+		page, response, err := a.CrusoeAPIClient.VMsApi.ListInstances(WithOperation(ctx, "ListInstances"),
+			projectID, opts)
+		if response != nil {
+			response.Body.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list instances: %w", err)
+		}
+		instances = append(instances, page.Items...)
+		if page.NextPageToken == "" {
+			return instances, nil
+		}
+		opts.NextToken = optional.NewString(page.NextPageToken)
+	}
+}
+
+func (a *APIClientImpl) ListInstanceGroups(ctx context.Context) ([]crusoeapi.InstanceGroup, error) {
+	projectID := os.Getenv(CrusoeProjectID)
+	if projectID == "" {
+		return nil, ErrProjectIDNotSet
+	}
+
+	groups, response, err := a.CrusoeAPIClient.InstanceGroupsApi.ListInstanceGroups(
+		WithOperation(ctx, "ListInstanceGroups"), projectID)
+	if response != nil {
+		defer response.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instance groups: %w", err)
+	}
+
+	return groups.Items, nil
+}
+
+func (a *APIClientImpl) SetInstanceGroupDesiredCount(ctx context.Context, instanceGroupID string,
+	desiredCount int64,
+) (*crusoeapi.InstanceGroup, error) {
+	projectID := os.Getenv(CrusoeProjectID)
+	if projectID == "" {
+		return nil, ErrProjectIDNotSet
+	}
+	if desiredCount <= 0 {
+		return nil, ErrZeroDesiredCount
+	}
+
+	group, response, err := a.CrusoeAPIClient.InstanceGroupsApi.PatchInstanceGroup(
+		WithOperation(ctx, "PatchInstanceGroup"),
+		crusoeapi.InstanceGroupPatchRequest{DesiredCount: &crusoeapi.DesiredCount{Value: desiredCount}},
+		instanceGroupID, projectID)
+	if response != nil {
+		defer response.Body.Close()
+	}
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return nil, ErrInstanceGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update instance group: %w", err)
+	}
+
+	return &group, nil
+}
+
+func (a *APIClientImpl) GetInstanceTemplate(ctx context.Context, instanceTemplateID string,
+) (*crusoeapi.InstanceTemplate, error) {
+	projectID := os.Getenv(CrusoeProjectID)
+	if projectID == "" {
+		return nil, ErrProjectIDNotSet
+	}
+
+	template, response, err := a.CrusoeAPIClient.InstanceTemplatesApi.GetInstanceTemplate(
+		WithOperation(ctx, "GetInstanceTemplate"), instanceTemplateID, projectID)
+	if response != nil {
+		defer response.Body.Close()
+	}
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return nil, ErrInstanceTemplateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance template: %w", err)
+	}
+
+	return &template, nil
+}
+
+func (a *APIClientImpl) DeleteInstance(ctx context.Context, instanceID string) error {
+	projectID := os.Getenv(CrusoeProjectID)
+	if projectID == "" {
+		return ErrProjectIDNotSet
+	}
+
+	_, response, err := a.CrusoeAPIClient.VMsApi.DeleteInstance(
+		WithOperation(ctx, "DeleteInstance"), projectID, instanceID)
+	if response != nil {
+		defer response.Body.Close()
+	}
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return ErrInstanceNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete instance: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:paralleltest // sets CRUSOE_PROJECT_ID
+func TestGetInstanceByName(t *testing.T) {
+	t.Setenv(client.CrusoeProjectID, "project")
+
+	tests := []struct {
+		name      string
+		instances []crusoeapi.InstanceV1Alpha5
+		wantID    string
+		wantErr   error
+	}{
+		{
+			name:    "no instances",
+			wantErr: client.ErrInstanceNotFound,
+		},
+		{
+			name:      "single instance",
+			instances: []crusoeapi.InstanceV1Alpha5{{Id: "a", Name: "node"}},
+			wantID:    "a",
+		},
+		{
+			name: "exact name match",
+			instances: []crusoeapi.InstanceV1Alpha5{
+				{Id: "a", Name: "node-1"},
+				{Id: "b", Name: "node"},
+			},
+			wantID: "b",
+		},
+		{
+			name: "several exact name matches",
+			instances: []crusoeapi.InstanceV1Alpha5{
+				{Id: "a", Name: "node"},
+				{Id: "b", Name: "node"},
+			},
+			wantErr: client.ErrAmbiguousInstanceName,
+		},
+		{
+			name: "several instances without an exact match",
+			instances: []crusoeapi.InstanceV1Alpha5{
+				{Id: "a", Name: "node-1"},
+				{Id: "b", Name: "node-2"},
+			},
+			wantErr: client.ErrAmbiguousInstanceName,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(crusoeapi.ListInstancesResponseV1Alpha5{Items: test.instances})
+			}))
+			defer server.Close()
+
+			cfg := crusoeapi.NewConfiguration()
+			cfg.BasePath = server.URL
+			cfg.HTTPClient = server.Client()
+			apiClient := &client.APIClientImpl{CrusoeAPIClient: crusoeapi.NewAPIClient(cfg)}
+
+			instance, err := apiClient.GetInstanceByName(context.Background(), "node.cluster.local")
+			if test.wantErr != nil {
+				require.ErrorIs(t, err, test.wantErr)
+
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.wantID, instance.Id)
+		})
+	}
+}
+
+//nolint:paralleltest // sets CRUSOE_PROJECT_ID
+func TestListInstancesFollowsPages(t *testing.T) {
+	t.Setenv(client.CrusoeProjectID, "project")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := crusoeapi.ListInstancesResponseV1Alpha5{
+			Items:         []crusoeapi.InstanceV1Alpha5{{Id: "a"}},
+			NextPageToken: "page-2",
+		}
+		if r.URL.Query().Get("next_token") == "page-2" {
+			response = crusoeapi.ListInstancesResponseV1Alpha5{Items: []crusoeapi.InstanceV1Alpha5{{Id: "b"}}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	cfg := crusoeapi.NewConfiguration()
+	cfg.BasePath = server.URL
+	cfg.HTTPClient = server.Client()
+	apiClient := &client.APIClientImpl{CrusoeAPIClient: crusoeapi.NewAPIClient(cfg)}
+
+	instances, err := apiClient.ListInstances(context.Background())
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	require.Equal(t, "b", instances[1].Id)
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"k8s.io/klog/v2"
+)
+
+// InstrumentedAPIClient is an APIClient decorator recording the latency and errors
+// of every call, and tracing it.
+type InstrumentedAPIClient struct {
+	next APIClient
+}
+
+// NewInstrumentedAPIClient returns an APIClient recording metrics for the calls to next.
+func NewInstrumentedAPIClient(next APIClient) *InstrumentedAPIClient {
+	registerMetrics()
+
+	return &InstrumentedAPIClient{next: next}
+}
+
+// start starts the span of a call, whose Crusoe API requests are its children. The
+// returned function ends the span and records the metrics of the call.
+func start(ctx context.Context, method string) (context.Context, func(error)) {
+	begin := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "APIClient."+method)
+
+	return ctx, func(err error) {
+		duration := time.Since(begin)
+		klog.V(4).InfoS("Crusoe API client call", "operation", method, "duration", duration, "err", err)
+		callDuration.WithLabelValues(method).Observe(duration.Seconds())
+		calls.WithLabelValues(method, errorType(err)).Inc()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (c *InstrumentedAPIClient) GetInstanceByName(ctx context.Context, nodeName string,
+) (*crusoeapi.InstanceV1Alpha5, error) {
+	ctx, done := start(ctx, "GetInstanceByName")
+	instance, err := c.next.GetInstanceByName(ctx, nodeName)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return instance, err
+}
+
+func (c *InstrumentedAPIClient) GetIBNetwork(ctx context.Context, projectID, ibPartitionID string,
+) (*crusoeapi.IbPartition, error) {
+	ctx, done := start(ctx, "GetIBNetwork")
+	ibPartition, err := c.next.GetIBNetwork(ctx, projectID, ibPartitionID)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return ibPartition, err
+}
+
+func (c *InstrumentedAPIClient) GetInstanceByID(ctx context.Context, instanceID string,
+) (*crusoeapi.InstanceV1Alpha5, *http.Response, error) {
+	ctx, done := start(ctx, "GetInstanceByID")
+	instance, response, err := c.next.GetInstanceByID(ctx, instanceID)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return instance, response, err
+}
+
+func (c *InstrumentedAPIClient) GetVMType(ctx context.Context, projectID, productName string,
+) (*crusoeapi.ModelType, error) {
+	ctx, done := start(ctx, "GetVMType")
+	vmType, err := c.next.GetVMType(ctx, projectID, productName)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return vmType, err
+}
+
+func (c *InstrumentedAPIClient) GetDisk(ctx context.Context, diskID string) (*crusoeapi.DiskV1Alpha5, error) {
+	ctx, done := start(ctx, "GetDisk")
+	disk, err := c.next.GetDisk(ctx, diskID)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return disk, err
+}
+
+func (c *InstrumentedAPIClient) ListClusters(ctx context.Context) ([]crusoeapi.KubernetesCluster, error) {
+	ctx, done := start(ctx, "ListClusters")
+	clusters, err := c.next.ListClusters(ctx)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return clusters, err
+}
+
+func (c *InstrumentedAPIClient) ListInstances(ctx context.Context) ([]crusoeapi.InstanceV1Alpha5, error) {
+	ctx, done := start(ctx, "ListInstances")
+	instances, err := c.next.ListInstances(ctx)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return instances, err
+}
+
+func (c *InstrumentedAPIClient) ListInstanceGroups(ctx context.Context) ([]crusoeapi.InstanceGroup, error) {
+	ctx, done := start(ctx, "ListInstanceGroups")
+	groups, err := c.next.ListInstanceGroups(ctx)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return groups, err
+}
+
+func (c *InstrumentedAPIClient) SetInstanceGroupDesiredCount(ctx context.Context, instanceGroupID string,
+	desiredCount int64,
+) (*crusoeapi.InstanceGroup, error) {
+	ctx, done := start(ctx, "SetInstanceGroupDesiredCount")
+	group, err := c.next.SetInstanceGroupDesiredCount(ctx, instanceGroupID, desiredCount)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return group, err
+}
+
+func (c *InstrumentedAPIClient) GetInstanceTemplate(ctx context.Context, instanceTemplateID string,
+) (*crusoeapi.InstanceTemplate, error) {
+	ctx, done := start(ctx, "GetInstanceTemplate")
+	template, err := c.next.GetInstanceTemplate(ctx, instanceTemplateID)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return template, err
+}
+
+func (c *InstrumentedAPIClient) DeleteInstance(ctx context.Context, instanceID string) error {
+	ctx, done := start(ctx, "DeleteInstance")
+	err := c.next.DeleteInstance(ctx, instanceID)
+	done(err)
+
+	//nolint:wrapcheck // decorator, the error is returned as is
+	return err
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	metricsNamespace = "crusoe"
+	metricsSubsystem = "api"
+
+	errorTypeNone      = "none"
+	errorTypeTimeout   = "timeout"
+	errorTypeCanceled  = "canceled"
+	errorTypeNotFound  = "not_found"
+	errorTypeAmbiguous = "ambiguous"
+	errorTypeConfig    = "config"
+	errorTypeSignature = "signature"
+	errorTypeNetwork   = "network"
+	errorTypeAPI       = "api"
+
+	authFailureUnauthorized = "unauthorized"
+	authFailureForbidden    = "forbidden"
+
+	unknownOperation = "unknown"
+)
+
+//nolint:gochecknoglobals // metrics are registered once on the global registry
+var (
+	requestDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "request_duration_seconds",
+			Help:           "Latency of Crusoe API requests by operation and HTTP status class.",
+			Buckets:        metrics.ExponentialBuckets(0.01, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation", "code_class"},
+	)
+
+	requests = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "requests_total",
+			Help:           "Number of Crusoe API requests by operation, HTTP status class and error type.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation", "code_class", "error"},
+	)
+
+	authFailures = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "auth_failures_total",
+			Help:           "Number of Crusoe API requests that failed to authenticate, by operation and reason.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation", "reason"},
+	)
+
+	callDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "client_call_duration_seconds",
+			Help:           "Latency of API client calls, which may make several Crusoe API requests, by method.",
+			Buckets:        metrics.ExponentialBuckets(0.01, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"method"},
+	)
+
+	calls = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "client_calls_total",
+			Help:           "Number of API client calls by method and error type.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"method", "error"},
+	)
+
+	metricRegistration sync.Once
+)
+
+// registerMetrics registers the metrics that are to be monitored.
+func registerMetrics() {
+	metricRegistration.Do(func() {
+		legacyregistry.MustRegister(requestDuration)
+		legacyregistry.MustRegister(requests)
+		legacyregistry.MustRegister(authFailures)
+		legacyregistry.MustRegister(callDuration)
+		legacyregistry.MustRegister(calls)
+	})
+}
+
+type operationKey struct{}
+
+// WithOperation returns a context labelling the Crusoe API requests made with it
+// by the API operation, e.g. ListInstances.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+func operationFrom(ctx context.Context) string {
+	if operation, ok := ctx.Value(operationKey{}).(string); ok {
+		return operation
+	}
+
+	return unknownOperation
+}
+
+// errorType classifies an error for the error metric labels.
+func errorType(err error) string {
+	switch {
+	case err == nil:
+		return errorTypeNone
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorTypeTimeout
+	case errors.Is(err, context.Canceled):
+		return errorTypeCanceled
+	case errors.Is(err, ErrNotFound):
+		return errorTypeNotFound
+	case errors.Is(err, ErrAmbiguousInstanceName):
+		return errorTypeAmbiguous
+	case errors.Is(err, ErrProjectIDNotSet):
+		return errorTypeConfig
+	}
+
+	return errorTypeAPI
+}
@@ -13,7 +13,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 )
 
-// MockApiClient is a mock of ApiClient interface.
+// MockApiClient is a mock of APIClient interface.
 type MockApiClient struct {
 	ctrl     *gomock.Controller
 	recorder *MockApiClientMockRecorder
@@ -36,6 +36,35 @@ func (m *MockApiClient) EXPECT() *MockApiClientMockRecorder {
 	return m.recorder
 }
 
+// DeleteInstance mocks base method.
+func (m *MockApiClient) DeleteInstance(ctx context.Context, instanceID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteInstance", ctx, instanceID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteInstance indicates an expected call of DeleteInstance.
+func (mr *MockApiClientMockRecorder) DeleteInstance(ctx, instanceID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteInstance", reflect.TypeOf((*MockApiClient)(nil).DeleteInstance), ctx, instanceID)
+}
+
+// GetDisk mocks base method.
+func (m *MockApiClient) GetDisk(ctx context.Context, diskID string) (*swagger.DiskV1Alpha5, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDisk", ctx, diskID)
+	ret0, _ := ret[0].(*swagger.DiskV1Alpha5)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDisk indicates an expected call of GetDisk.
+func (mr *MockApiClientMockRecorder) GetDisk(ctx, diskID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDisk", reflect.TypeOf((*MockApiClient)(nil).GetDisk), ctx, diskID)
+}
+
 // GetIBNetwork mocks base method.
 func (m *MockApiClient) GetIBNetwork(ctx context.Context, projectID, ibPartitionID string) (*swagger.IbPartition, error) {
 	m.ctrl.T.Helper()
@@ -52,9 +81,9 @@ func (mr *MockApiClientMockRecorder) GetIBNetwork(ctx, projectID, ibPartitionID
 }
 
 // GetInstanceByID mocks base method.
-func (m *MockApiClient) GetInstanceByID(ctx context.Context, instanceId string) (*swagger.InstanceV1Alpha5, *http.Response, error) {
+func (m *MockApiClient) GetInstanceByID(ctx context.Context, instanceID string) (*swagger.InstanceV1Alpha5, *http.Response, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetInstanceByID", ctx, instanceId)
+	ret := m.ctrl.Call(m, "GetInstanceByID", ctx, instanceID)
 	ret0, _ := ret[0].(*swagger.InstanceV1Alpha5)
 	ret1, _ := ret[1].(*http.Response)
 	ret2, _ := ret[2].(error)
@@ -62,9 +91,9 @@ func (m *MockApiClient) GetInstanceByID(ctx context.Context, instanceId string)
 }
 
 // GetInstanceByID indicates an expected call of GetInstanceByID.
-func (mr *MockApiClientMockRecorder) GetInstanceByID(ctx, instanceId interface{}) *gomock.Call {
+func (mr *MockApiClientMockRecorder) GetInstanceByID(ctx, instanceID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceByID", reflect.TypeOf((*MockApiClient)(nil).GetInstanceByID), ctx, instanceId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceByID", reflect.TypeOf((*MockApiClient)(nil).GetInstanceByID), ctx, instanceID)
 }
 
 // GetInstanceByName mocks base method.
@@ -81,3 +110,93 @@ func (mr *MockApiClientMockRecorder) GetInstanceByName(ctx, nodeName interface{}
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceByName", reflect.TypeOf((*MockApiClient)(nil).GetInstanceByName), ctx, nodeName)
 }
+
+// GetInstanceTemplate mocks base method.
+func (m *MockApiClient) GetInstanceTemplate(ctx context.Context, instanceTemplateID string) (*swagger.InstanceTemplate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInstanceTemplate", ctx, instanceTemplateID)
+	ret0, _ := ret[0].(*swagger.InstanceTemplate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInstanceTemplate indicates an expected call of GetInstanceTemplate.
+func (mr *MockApiClientMockRecorder) GetInstanceTemplate(ctx, instanceTemplateID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceTemplate", reflect.TypeOf((*MockApiClient)(nil).GetInstanceTemplate), ctx, instanceTemplateID)
+}
+
+// GetVMType mocks base method.
+func (m *MockApiClient) GetVMType(ctx context.Context, projectID, productName string) (*swagger.ModelType, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVMType", ctx, projectID, productName)
+	ret0, _ := ret[0].(*swagger.ModelType)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVMType indicates an expected call of GetVMType.
+func (mr *MockApiClientMockRecorder) GetVMType(ctx, projectID, productName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVMType", reflect.TypeOf((*MockApiClient)(nil).GetVMType), ctx, projectID, productName)
+}
+
+// ListClusters mocks base method.
+func (m *MockApiClient) ListClusters(ctx context.Context) ([]swagger.KubernetesCluster, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListClusters", ctx)
+	ret0, _ := ret[0].([]swagger.KubernetesCluster)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListClusters indicates an expected call of ListClusters.
+func (mr *MockApiClientMockRecorder) ListClusters(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListClusters", reflect.TypeOf((*MockApiClient)(nil).ListClusters), ctx)
+}
+
+// ListInstanceGroups mocks base method.
+func (m *MockApiClient) ListInstanceGroups(ctx context.Context) ([]swagger.InstanceGroup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInstanceGroups", ctx)
+	ret0, _ := ret[0].([]swagger.InstanceGroup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInstanceGroups indicates an expected call of ListInstanceGroups.
+func (mr *MockApiClientMockRecorder) ListInstanceGroups(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInstanceGroups", reflect.TypeOf((*MockApiClient)(nil).ListInstanceGroups), ctx)
+}
+
+// ListInstances mocks base method.
+func (m *MockApiClient) ListInstances(ctx context.Context) ([]swagger.InstanceV1Alpha5, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInstances", ctx)
+	ret0, _ := ret[0].([]swagger.InstanceV1Alpha5)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInstances indicates an expected call of ListInstances.
+func (mr *MockApiClientMockRecorder) ListInstances(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInstances", reflect.TypeOf((*MockApiClient)(nil).ListInstances), ctx)
+}
+
+// SetInstanceGroupDesiredCount mocks base method.
+func (m *MockApiClient) SetInstanceGroupDesiredCount(ctx context.Context, instanceGroupID string, desiredCount int64) (*swagger.InstanceGroup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetInstanceGroupDesiredCount", ctx, instanceGroupID, desiredCount)
+	ret0, _ := ret[0].(*swagger.InstanceGroup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetInstanceGroupDesiredCount indicates an expected call of SetInstanceGroupDesiredCount.
+func (mr *MockApiClientMockRecorder) SetInstanceGroupDesiredCount(ctx, instanceGroupID, desiredCount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInstanceGroupDesiredCount", reflect.TypeOf((*MockApiClient)(nil).SetInstanceGroupDesiredCount), ctx, instanceGroupID, desiredCount)
+}
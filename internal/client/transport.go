@@ -0,0 +1,74 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/auth"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const codeClassNone = "none"
+
+// InstrumentedTransport is an http.RoundTripper recording the latency, status
+// and errors of Crusoe API requests, labelled by the operation set with WithOperation.
+type InstrumentedTransport struct {
+	http.RoundTripper
+}
+
+// NewInstrumentedTransport instruments the requests sent through the round tripper,
+// which should be the authenticating transport so signing failures are counted.
+func NewInstrumentedTransport(r http.RoundTripper) http.RoundTripper {
+	registerMetrics()
+
+	return InstrumentedTransport{RoundTripper: r}
+}
+
+func (t InstrumentedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	operation := operationFrom(r.Context())
+	start := time.Now()
+	response, err := t.RoundTripper.RoundTrip(r)
+
+	codeClass := codeClassNone
+	if response != nil {
+		codeClass = strconv.Itoa(response.StatusCode/100) + "xx" //nolint:mnd // HTTP status classes
+	}
+	requestDuration.WithLabelValues(operation, codeClass).Observe(time.Since(start).Seconds())
+
+	errType := errorTypeNone
+	switch {
+	case errors.Is(err, auth.ErrSignRequest):
+		errType = errorTypeSignature
+		authFailures.WithLabelValues(operation, errorTypeSignature).Inc()
+	case err != nil:
+		errType = errorType(err)
+		if errType == errorTypeAPI {
+			errType = errorTypeNetwork
+		}
+	case response.StatusCode == http.StatusUnauthorized:
+		errType = errorTypeAPI
+		authFailures.WithLabelValues(operation, authFailureUnauthorized).Inc()
+	case response.StatusCode == http.StatusForbidden:
+		errType = errorTypeAPI
+		authFailures.WithLabelValues(operation, authFailureForbidden).Inc()
+	case response.StatusCode == http.StatusNotFound:
+		errType = errorTypeNotFound
+	case response.StatusCode >= http.StatusBadRequest:
+		errType = errorTypeAPI
+	}
+	requests.WithLabelValues(operation, codeClass, errType).Inc()
+
+	//nolint:wrapcheck // error should be forwarded here.
+	return response, err
+}
+
+// NewTracingTransport traces the requests sent through the round tripper as children
+// of the span in their context, and propagates the trace context to the Crusoe API
+// in the request headers.
+func NewTracingTransport(r http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(r, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return "CrusoeAPI." + operationFrom(r.Context())
+	}))
+}
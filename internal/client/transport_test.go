@@ -0,0 +1,156 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/auth"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/tracing"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// metricValue sums the counters with the name whose labels include all the given ones.
+func metricValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	sum := 0.0
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			matched := 0
+			for _, pair := range metric.GetLabel() {
+				if value, ok := labels[pair.GetName()]; ok && value == pair.GetValue() {
+					matched++
+				}
+			}
+			if matched == len(labels) {
+				sum += metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return sum
+}
+
+func TestInstrumentedTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{
+		Transport: client.NewInstrumentedTransport(auth.NewAuthenticatingTransport(nil, "key", "c2VjcmV0")),
+	}
+	get := func(ctx context.Context, path string) error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		response, err := httpClient.Do(request)
+		if response != nil {
+			response.Body.Close()
+		}
+
+		return err
+	}
+
+	require.NoError(t, get(client.WithOperation(context.Background(), "TestOK"), "/ok"))
+	require.NoError(t, get(client.WithOperation(context.Background(), "TestForbidden"), "/forbidden"))
+	require.NoError(t, get(client.WithOperation(context.Background(), "TestNotFound"), "/missing"))
+
+	require.InDelta(t, 1.0, metricValue(t, "crusoe_api_requests_total",
+		map[string]string{"operation": "TestOK", "code_class": "2xx", "error": "none"}), 0)
+	require.InDelta(t, 1.0, metricValue(t, "crusoe_api_requests_total",
+		map[string]string{"operation": "TestForbidden", "code_class": "4xx", "error": "api"}), 0)
+	require.InDelta(t, 1.0, metricValue(t, "crusoe_api_requests_total",
+		map[string]string{"operation": "TestNotFound", "code_class": "4xx", "error": "not_found"}), 0)
+	require.InDelta(t, 1.0, metricValue(t, "crusoe_api_auth_failures_total",
+		map[string]string{"operation": "TestForbidden", "reason": "forbidden"}), 0)
+
+	// a secret that is not base64 cannot sign requests
+	httpClient.Transport = client.NewInstrumentedTransport(auth.NewAuthenticatingTransport(nil, "key", "!"))
+	require.ErrorIs(t, get(client.WithOperation(context.Background(), "TestSignature"), "/ok"), auth.ErrSignRequest)
+	require.InDelta(t, 1.0, metricValue(t, "crusoe_api_auth_failures_total",
+		map[string]string{"operation": "TestSignature", "reason": "signature"}), 0)
+}
+
+func TestInstrumentedAPIClient(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	mockClient.EXPECT().GetDisk(gomock.Any(), "missing").Return(nil, client.ErrDiskNotFound)
+	mockClient.EXPECT().SetInstanceGroupDesiredCount(gomock.Any(), "missing", int64(1)).
+		Return(nil, client.ErrInstanceGroupNotFound)
+	mockClient.EXPECT().GetInstanceByName(gomock.Any(), "node").Return(nil, client.ErrAmbiguousInstanceName)
+	instrumented := client.NewInstrumentedAPIClient(mockClient)
+
+	calls := func(method, errorType string) float64 {
+		return metricValue(t, "crusoe_api_client_calls_total", map[string]string{"method": method, "error": errorType})
+	}
+	beforeDisk, beforeGroup, beforeName := calls("GetDisk", "not_found"), calls("SetInstanceGroupDesiredCount", "not_found"),
+		calls("GetInstanceByName", "ambiguous")
+
+	_, err := instrumented.GetDisk(context.Background(), "missing")
+	require.ErrorIs(t, err, client.ErrDiskNotFound)
+	_, err = instrumented.SetInstanceGroupDesiredCount(context.Background(), "missing", 1)
+	require.ErrorIs(t, err, client.ErrNotFound)
+	_, err = instrumented.GetInstanceByName(context.Background(), "node")
+	require.ErrorIs(t, err, client.ErrAmbiguousInstanceName)
+
+	require.InDelta(t, beforeDisk+1, calls("GetDisk", "not_found"), 0)
+	require.InDelta(t, beforeGroup+1, calls("SetInstanceGroupDesiredCount", "not_found"), 0)
+	require.InDelta(t, beforeName+1, calls("GetInstanceByName", "ambiguous"), 0)
+}
+
+//nolint:paralleltest // sets the global tracer provider
+func TestTracingTransportPropagatesTraceContext(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Register(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	traceparent := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("Traceparent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, span := tracing.Tracer().Start(context.Background(), "parent")
+	request, err := http.NewRequestWithContext(client.WithOperation(ctx, "ListInstances"), http.MethodGet,
+		server.URL, nil)
+	require.NoError(t, err)
+	response, err := (&http.Client{Transport: client.NewTracingTransport(http.DefaultTransport)}).Do(request)
+	require.NoError(t, err)
+	response.Body.Close()
+	span.End()
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(t, spans, 2)
+	require.Equal(t, "CrusoeAPI.ListInstances", spans[0].Name())
+	require.Equal(t, span.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	require.Contains(t, <-traceparent, spans[0].SpanContext().TraceID().String())
+}
@@ -2,13 +2,22 @@ package crusoe
 
 import (
 	"io"
+	"math"
 	"os"
+	"time"
 
 	auth "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/auth"
 	client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	clusters "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/clusters"
 	instances "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
 )
 
 const (
@@ -16,29 +25,76 @@ const (
 	APIEndpoint  = "CRUSOE_API_ENDPOINT"
 	AccessKey    = "CRUSOE_ACCESS_KEY"
 	SecretKey    = "CRUSOE_SECRET_KEY"
+
+	eventSourceComponent = "crusoe-cloud-provider"
+	// Similar events on a node are aggregated into one event after this many are
+	// emitted within the interval, so a failing lookup does not flood the node's events.
+	eventAggregationMaxEvents = 5
+	eventAggregationInterval  = 30 * 60
+
+	// A cluster ID that cannot be looked up is retried with an exponential backoff.
+	clusterIDRetryDelay    = time.Second
+	clusterIDMaxRetryDelay = 5 * time.Minute
 )
 
 type Cloud struct {
 	crusoeInstances *instances.Instances
+	crusoeClusters  *clusters.Clusters
 }
 
 // revive:disable:unused-parameter
 func (c *Cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
-	clientset := clientBuilder.ClientOrDie("crusoe-shared-informers")
-	sharedInformer := informers.NewSharedInformerFactory(clientset, 0)
-	sharedInformer.Start(nil)
-	sharedInformer.WaitForCacheSync(nil)
+	kubeClient := clientBuilder.ClientOrDie("crusoe-cloud-provider")
+	c.crusoeInstances.SetKubeClient(kubeClient)
+
+	broadcaster := record.NewBroadcaster(record.WithCorrelatorOptions(record.CorrelatorOptions{
+		MaxEvents:            eventAggregationMaxEvents,
+		MaxIntervalInSeconds: eventAggregationInterval,
+	}))
+	broadcaster.StartStructuredLogging(4)
+	broadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	go func() {
+		<-stop
+		broadcaster.Shutdown()
+	}()
+	c.crusoeInstances.SetEventRecorder(
+		broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventSourceComponent}))
+
+	c.initializeClusterID(stop)
+}
+
+// initializeClusterID resolves the cluster ID and labels nodes with it. Lookups are
+// retried until the ID is resolved, so that the controllers only start once every node
+// they initialize can be labeled with it.
+func (c *Cloud) initializeClusterID(stop <-chan struct{}) {
+	if !c.crusoeClusters.HasID() {
+		return
+	}
+	clusterID, err := c.crusoeClusters.WaitForID(wait.ContextForChannel(stop), wait.Backoff{
+		Duration: clusterIDRetryDelay,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    math.MaxInt32,
+		Cap:      clusterIDMaxRetryDelay,
+	})
+	if err != nil {
+		klog.ErrorS(err, "Stopped waiting for the cluster ID")
+
+		return
+	}
+	klog.InfoS("Using cluster ID", "clusterID", clusterID)
+	c.crusoeInstances.SetClusterID(clusterID)
 }
 
+// LoadBalancer is not implemented, so the service controller does not provision Crusoe load
+// balancers for LoadBalancer Services and their annotations are not read.
 func (c *Cloud) LoadBalancer() (cloudprovider.LoadBalancer, bool) { return nil, false }
 
 func (c *Cloud) Instances() (cloudprovider.Instances, bool) { return c.crusoeInstances, true }
 
 func (c *Cloud) InstancesV2() (cloudprovider.InstancesV2, bool) { return c.crusoeInstances, true }
 
-func (c *Cloud) Clusters() (cloudprovider.Clusters, bool) {
-	return nil, false
-}
+func (c *Cloud) Clusters() (cloudprovider.Clusters, bool) { return c.crusoeClusters, true }
 
 func (c *Cloud) Routes() (cloudprovider.Routes, bool) {
 	return nil, false
@@ -50,27 +106,37 @@ func (c *Cloud) Zones() (cloudprovider.Zones, bool) {
 
 func (c *Cloud) ProviderName() string { return ProviderName }
 
-func (c *Cloud) HasClusterID() bool {
-	return true
+// SetInformers lets the instances drop the state they track for deleted nodes.
+func (c *Cloud) SetInformers(informerFactory informers.SharedInformerFactory) {
+	c.crusoeInstances.SetInformers(informerFactory)
 }
 
+// HasClusterID reports whether a cluster ID is configured in the cloud config.
+func (c *Cloud) HasClusterID() bool { return c.crusoeClusters.HasID() }
+
 func RegisterCloudProvider() {
-	cloudprovider.RegisterCloudProvider(ProviderName, func(io.Reader) (cloudprovider.Interface, error) {
-		return newCloud()
+	cloudprovider.RegisterCloudProvider(ProviderName, func(config io.Reader) (cloudprovider.Interface, error) {
+		cloudConfig, err := readCloudConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		return newCloud(cloudConfig)
 	})
 }
 
-func newCloud() (cloudprovider.Interface, error) {
+func newCloud(cloudConfig *CloudConfig) (cloudprovider.Interface, error) {
 	apiEndPoint := os.Getenv(APIEndpoint)
 	apiAccessKey := os.Getenv(AccessKey)
 	apiSecretKey := os.Getenv(SecretKey)
 	cc := auth.NewCrusoeClient(apiEndPoint, apiAccessKey, apiSecretKey,
-		"crusoe-cloud-controller-manager/0.0.1")
-	apiClient := &client.APIClientImpl{
+		"crusoe-cloud-controller-manager/0.0.1", client.NewInstrumentedTransport, client.NewTracingTransport)
+	apiClient := client.NewInstrumentedAPIClient(&client.APIClientImpl{
 		CrusoeAPIClient: cc,
-	}
+	})
 
 	return &Cloud{
-		crusoeInstances: instances.NewCrusoeInstances(apiClient),
+		crusoeInstances: instances.NewCrusoeInstancesWithConfig(apiClient, cloudConfig.Instances),
+		crusoeClusters:  clusters.NewClusters(apiClient, cloudConfig.Cluster),
 	}, nil
 }
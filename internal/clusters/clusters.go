@@ -0,0 +1,176 @@
+package clusters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// idLookupTimeout bounds every lookup of the cluster ID by WaitForID.
+const idLookupTimeout = 30 * time.Second
+
+var (
+	ErrInvalidConfig    = errors.New("invalid cluster config")
+	ErrNoClusterID      = errors.New("no cluster ID is configured")
+	ErrAmbiguousCluster = errors.New("more than one kubernetes cluster has the name")
+)
+
+// Config identifies the cluster the cloud controller manager runs for.
+type Config struct {
+	// ID is the cluster ID. For Crusoe managed clusters it is the ID of the Kubernetes cluster resource.
+	ID string `json:"id,omitempty"`
+	// Name is the name of a Crusoe managed Kubernetes cluster whose ID is used when ID is not set.
+	Name string `json:"name,omitempty"`
+}
+
+// Validate returns an error if the config cannot be used.
+func (c *Config) Validate() error {
+	if c.ID != "" && c.Name != "" {
+		return fmt.Errorf("%w: only one of id and name can be set", ErrInvalidConfig)
+	}
+	// the ID is used as a label value on nodes
+	if errs := validation.IsValidLabelValue(c.ID); len(errs) > 0 {
+		return fmt.Errorf("%w: id %q: %s", ErrInvalidConfig, c.ID, strings.Join(errs, "; "))
+	}
+	// the name is matched exactly against the names of the clusters of the project
+	if strings.TrimSpace(c.Name) != c.Name {
+		return fmt.Errorf("%w: name %q must not start or end with whitespace", ErrInvalidConfig, c.Name)
+	}
+
+	return nil
+}
+
+// Clusters implements cloudprovider.Clusters for Crusoe managed Kubernetes clusters and
+// resolves the ID of the cluster the cloud controller manager runs for.
+type Clusters struct {
+	apiClient client.APIClient
+	config    Config
+
+	mu sync.Mutex
+	id string
+}
+
+// NewClusters creates Clusters using the given cloud config settings.
+func NewClusters(c client.APIClient, config Config) *Clusters {
+	registerMetrics()
+
+	return &Clusters{
+		apiClient: c,
+		config:    config,
+	}
+}
+
+// HasID reports whether a cluster ID is configured, either directly or through the
+// name of a Crusoe managed cluster.
+func (c *Clusters) HasID() bool {
+	return c.config.ID != "" || c.config.Name != ""
+}
+
+// ID returns the cluster ID. When the config names a Crusoe managed cluster its ID is
+// looked up once and cached, failed lookups are retried on the next call.
+func (c *Clusters) ID(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.id != "" {
+		return c.id, nil
+	}
+
+	switch {
+	case c.config.ID != "":
+		c.id = c.config.ID
+	case c.config.Name != "":
+		cluster, err := c.clusterByName(ctx, c.config.Name)
+		if err != nil {
+			return "", err
+		}
+		klog.InfoS("Discovered cluster ID", "cluster", c.config.Name, "clusterID", cluster.Id)
+		c.id = cluster.Id
+	default:
+		return "", ErrNoClusterID
+	}
+	clusterInfo.WithLabelValues(c.id).Set(1)
+
+	return c.id, nil
+}
+
+// WaitForID returns the cluster ID like ID, retrying failed lookups with the backoff
+// until the ID is resolved or the context is done. The backoff keeps retrying at its cap.
+func (c *Clusters) WaitForID(ctx context.Context, backoff wait.Backoff) (string, error) {
+	for {
+		lookupCtx, cancel := context.WithTimeout(ctx, idLookupTimeout)
+		id, err := c.ID(lookupCtx)
+		cancel()
+		if err == nil {
+			return id, nil
+		}
+		klog.ErrorS(err, "Failed to get the cluster ID, retrying")
+
+		timer := time.NewTimer(backoff.Step())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return "", fmt.Errorf("failed to get the cluster ID: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// ListClusters returns the names of the Crusoe managed Kubernetes clusters in the project.
+func (c *Clusters) ListClusters(ctx context.Context) ([]string, error) {
+	clusters, err := c.apiClient.ListClusters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	names := make([]string, 0, len(clusters))
+	for index := range clusters {
+		names = append(names, clusters[index].Name)
+	}
+
+	return names, nil
+}
+
+// Master returns the DNS name of the control plane endpoint of the Crusoe managed
+// Kubernetes cluster with the given name.
+func (c *Clusters) Master(ctx context.Context, clusterName string) (string, error) {
+	cluster, err := c.clusterByName(ctx, clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	return cluster.DnsName, nil
+}
+
+func (c *Clusters) clusterByName(ctx context.Context, name string) (*crusoeapi.KubernetesCluster, error) {
+	clusters, err := c.apiClient.ListClusters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	var match *crusoeapi.KubernetesCluster
+	for index := range clusters {
+		if clusters[index].Name != name {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("%w %s", ErrAmbiguousCluster, name)
+		}
+		match = &clusters[index]
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w: %s", client.ErrClusterNotFound, name)
+	}
+
+	return match, nil
+}
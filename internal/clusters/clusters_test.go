@@ -0,0 +1,153 @@
+package clusters_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1alpha5 "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/clusters"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func testClusters() []v1alpha5.KubernetesCluster {
+	return []v1alpha5.KubernetesCluster{
+		{Id: "cluster-a", Name: "a", DnsName: "a.example.com"},
+		{Id: "cluster-b", Name: "b", DnsName: "b.example.com"},
+		{Id: "cluster-b2", Name: "b", DnsName: "b2.example.com"},
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, (&clusters.Config{}).Validate())
+	require.NoError(t, (&clusters.Config{ID: "cluster-a"}).Validate())
+	require.NoError(t, (&clusters.Config{Name: "a"}).Validate())
+	require.ErrorIs(t, (&clusters.Config{ID: "cluster-a", Name: "a"}).Validate(), clusters.ErrInvalidConfig)
+	require.ErrorIs(t, (&clusters.Config{ID: "not a label value"}).Validate(), clusters.ErrInvalidConfig)
+	require.ErrorIs(t, (&clusters.Config{Name: " a"}).Validate(), clusters.ErrInvalidConfig)
+	require.ErrorIs(t, (&clusters.Config{Name: "  "}).Validate(), clusters.ErrInvalidConfig)
+}
+
+func TestID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		config  clusters.Config
+		listed  bool
+		wantID  string
+		wantErr error
+	}{
+		{
+			name:    "not configured",
+			wantErr: clusters.ErrNoClusterID,
+		},
+		{
+			name:   "configured ID",
+			config: clusters.Config{ID: "cluster-a"},
+			wantID: "cluster-a",
+		},
+		{
+			name:   "discovered by name",
+			config: clusters.Config{Name: "a"},
+			listed: true,
+			wantID: "cluster-a",
+		},
+		{
+			name:    "unknown name",
+			config:  clusters.Config{Name: "c"},
+			listed:  true,
+			wantErr: client.ErrClusterNotFound,
+		},
+		{
+			name:    "ambiguous name",
+			config:  clusters.Config{Name: "b"},
+			listed:  true,
+			wantErr: clusters.ErrAmbiguousCluster,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mock_client.NewMockApiClient(ctrl)
+			if test.listed {
+				// a discovered ID is cached, failed lookups are retried
+				times := 2
+				if test.wantErr == nil {
+					times = 1
+				}
+				mockClient.EXPECT().ListClusters(gomock.Any()).Return(testClusters(), nil).Times(times)
+			}
+			clusterService := clusters.NewClusters(mockClient, test.config)
+			require.Equal(t, test.config.ID != "" || test.config.Name != "", clusterService.HasID())
+
+			for range 2 {
+				clusterID, err := clusterService.ID(context.Background())
+				if test.wantErr != nil {
+					require.ErrorIs(t, err, test.wantErr)
+
+					continue
+				}
+				require.NoError(t, err)
+				require.Equal(t, test.wantID, clusterID)
+			}
+		})
+	}
+}
+
+func TestWaitForID(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	backoff := wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 5, Cap: 4 * time.Millisecond}
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	gomock.InOrder(
+		mockClient.EXPECT().ListClusters(gomock.Any()).Return(nil, context.DeadlineExceeded),
+		mockClient.EXPECT().ListClusters(gomock.Any()).Return(testClusters(), nil),
+	)
+	clusterService := clusters.NewClusters(mockClient, clusters.Config{Name: "a"})
+
+	// a failed lookup is retried until the ID is resolved
+	clusterID, err := clusterService.WaitForID(context.Background(), backoff)
+	require.NoError(t, err)
+	require.Equal(t, "cluster-a", clusterID)
+
+	// a cluster that is never found is retried until the context is done
+	mockClient.EXPECT().ListClusters(gomock.Any()).Return(testClusters(), nil).AnyTimes()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = clusters.NewClusters(mockClient, clusters.Config{Name: "c"}).WaitForID(ctx, backoff)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestListClustersAndMaster(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	mockClient.EXPECT().ListClusters(gomock.Any()).Return(testClusters(), nil).AnyTimes()
+	clusterService := clusters.NewClusters(mockClient, clusters.Config{})
+
+	names, err := clusterService.ListClusters(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "b"}, names)
+
+	master, err := clusterService.Master(context.Background(), "a")
+	require.NoError(t, err)
+	require.Equal(t, "a.example.com", master)
+
+	_, err = clusterService.Master(context.Background(), "c")
+	require.ErrorIs(t, err, client.ErrClusterNotFound)
+}
@@ -0,0 +1,36 @@
+package clusters
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	metricsNamespace = "crusoe"
+	metricsSubsystem = "cluster"
+)
+
+//nolint:gochecknoglobals // metrics are registered once on the global registry
+var (
+	clusterInfo = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "info",
+			Help:           "Set to 1 with the ID of the cluster the cloud controller manager runs for.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"cluster_id"},
+	)
+
+	metricRegistration sync.Once
+)
+
+// registerMetrics registers the metrics that are to be monitored.
+func registerMetrics() {
+	metricRegistration.Do(func() {
+		legacyregistry.MustRegister(clusterInfo)
+	})
+}
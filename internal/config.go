@@ -0,0 +1,40 @@
+package crusoe
+
+import (
+	"fmt"
+	"io"
+
+	clusters "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/clusters"
+	instances "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"sigs.k8s.io/yaml"
+)
+
+// CloudConfig is the configuration read from the file passed with --cloud-config.
+// It can be written as YAML or JSON, and every field is optional.
+type CloudConfig struct {
+	Instances instances.Config `json:"instances,omitempty"`
+	Cluster   clusters.Config  `json:"cluster,omitempty"`
+}
+
+// readCloudConfig parses the cloud config, returning the defaults when no config is provided.
+func readCloudConfig(reader io.Reader) (*CloudConfig, error) {
+	cfg := &CloudConfig{}
+	if reader != nil {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cloud config: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse cloud config: %w", err)
+		}
+	}
+	cfg.Instances.ApplyDefaults()
+	if err := cfg.Instances.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid instances cloud config: %w", err)
+	}
+	if err := cfg.Cluster.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid cluster cloud config: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,339 @@
+package instancegroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	cloudprovider "k8s.io/cloud-provider"
+	controllersmetrics "k8s.io/component-base/metrics/prometheus/controllers"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	providerPrefix = "crusoe://"
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "crusoe-cloud-controller-manager"
+)
+
+var (
+	ErrNoCloudProvider            = errors.New("no cloud provider provided")
+	ErrNilKubernetesClient        = errors.New("kubernetes client is nil")
+	ErrInstanceGroupsNotSupported = errors.New("cloud provider does not support listing instance groups")
+	ErrNoGroupIDLabel             = errors.New("cloud provider does not label nodes with their instance group")
+)
+
+// Group is an instance group of the cloud provider.
+type Group struct {
+	ID           string
+	Name         string
+	DesiredCount int64
+	// RunningInstances and InactiveInstances are the IDs of the instances of the group.
+	RunningInstances  []string
+	InactiveInstances []string
+}
+
+// Lister lists the instance groups of the cloud provider. The Crusoe Instances
+// implement it.
+type Lister interface {
+	InstanceGroups(ctx context.Context) ([]Group, error)
+}
+
+// GroupIDLabeler is implemented by cloud providers that label nodes with the ID of the
+// instance group of their instance. The label is false when nodes are not labeled.
+type GroupIDLabeler interface {
+	GroupIDLabel() (string, bool)
+}
+
+// GroupSummary compares an instance group with the nodes of the cluster.
+type GroupSummary struct {
+	Name             string `json:"name,omitempty"`
+	DesiredCount     int64  `json:"desiredCount"`
+	RunningInstances int    `json:"runningInstances"`
+	// RegisteredNodes is the number of nodes whose instance is in the group.
+	RegisteredNodes int `json:"registeredNodes"`
+	// NotJoined are the IDs of running instances that have had no node for longer than the join timeout.
+	NotJoined []string `json:"notJoined,omitempty"`
+	// LeftGroup are the names of the nodes labeled with the group whose instance is no longer in it.
+	LeftGroup []string `json:"leftGroup,omitempty"`
+}
+
+// Controller reconciles the instance groups of the cloud provider with the nodes and
+// writes a summary of each group to a ConfigMap, surfacing instances that failed to
+// join the cluster and nodes whose instance left its group.
+type Controller struct {
+	kubeClient  clientset.Interface
+	nodeLister  corelisters.NodeLister
+	nodesSynced cache.InformerSynced
+	lister      Lister
+	options     *Options
+	// groupIDLabel is the node label holding the ID of the instance group of the node.
+	groupIDLabel string
+
+	// missingSince records when running instances were first seen without a node. It is
+	// only used by the sync loop.
+	missingSince map[string]time.Time
+}
+
+func NewController(
+	nodeInformer coreinformers.NodeInformer,
+	kubeClient clientset.Interface,
+	cloud cloudprovider.Interface,
+	options *Options,
+) (*Controller, error) {
+	if kubeClient == nil {
+		return nil, ErrNilKubernetesClient
+	}
+	if cloud == nil {
+		return nil, ErrNoCloudProvider
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	var lister Lister
+	var supported bool
+	if instancesV2, ok := cloud.InstancesV2(); ok {
+		lister, supported = instancesV2.(Lister)
+	}
+	if instances, ok := cloud.Instances(); !supported && ok {
+		lister, supported = instances.(Lister)
+	}
+	if !supported {
+		return nil, ErrInstanceGroupsNotSupported
+	}
+	// by default the label is the one the cloud provider sets with its label config
+	groupIDLabel := options.GroupIDLabel
+	if groupIDLabel == "" {
+		labeler, ok := lister.(GroupIDLabeler)
+		if ok {
+			groupIDLabel, ok = labeler.GroupIDLabel()
+		}
+		if !ok {
+			return nil, ErrNoGroupIDLabel
+		}
+	}
+	registerMetrics()
+
+	return &Controller{
+		kubeClient:   kubeClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		lister:       lister,
+		options:      options,
+		groupIDLabel: groupIDLabel,
+		missingSince: make(map[string]time.Time),
+	}, nil
+}
+
+// Run syncs the instance groups every sync period until the context is done.
+func (c *Controller) Run(ctx context.Context, controllerManagerMetrics *controllersmetrics.ControllerManagerMetrics) {
+	defer utilruntime.HandleCrash()
+	controllerManagerMetrics.ControllerStarted(ControllerName)
+	defer controllerManagerMetrics.ControllerStopped(ControllerName)
+
+	if !cache.WaitForCacheSync(ctx.Done(), c.nodesSynced) {
+		klog.ErrorS(nil, "Failed to wait for node caches to sync")
+
+		return
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.Sync(ctx); err != nil {
+			klog.ErrorS(err, "Failed to sync instance groups")
+		}
+	}, c.options.SyncPeriod)
+}
+
+// Sync compares the instance groups with the nodes and writes the summary.
+func (c *Controller) Sync(ctx context.Context) error {
+	groups, err := c.lister.InstanceGroups(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list instance groups: %w", err)
+	}
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	summary := c.summarize(groups, nodes, time.Now())
+	recordMetrics(summary)
+	for groupID, group := range summary {
+		if len(group.NotJoined) > 0 || len(group.LeftGroup) > 0 {
+			klog.InfoS("Instance group does not match its nodes", "instanceGroup", groupID,
+				"notJoined", group.NotJoined, "leftGroup", group.LeftGroup)
+		}
+	}
+
+	return c.writeSummary(ctx, summary)
+}
+
+// summarize builds the summary of the reported groups, keyed by group ID.
+func (c *Controller) summarize(groups []Group, nodes []*v1.Node, now time.Time) map[string]*GroupSummary {
+	nodeByInstance := make(map[string]*v1.Node, len(nodes))
+	for _, node := range nodes {
+		if instanceID, ok := strings.CutPrefix(node.Spec.ProviderID, providerPrefix); ok && instanceID != "" {
+			nodeByInstance[instanceID] = node
+		}
+	}
+
+	summary := make(map[string]*GroupSummary)
+	missing := sets.New[string]()
+	for index := range groups {
+		group := &groups[index]
+		if !c.reported(group, nodes, nodeByInstance) {
+			continue
+		}
+
+		groupSummary := &GroupSummary{
+			Name:             group.Name,
+			DesiredCount:     group.DesiredCount,
+			RunningInstances: len(group.RunningInstances),
+		}
+		for _, instanceID := range group.InactiveInstances {
+			if _, ok := nodeByInstance[instanceID]; ok {
+				groupSummary.RegisteredNodes++
+			}
+		}
+		for _, instanceID := range group.RunningInstances {
+			if _, ok := nodeByInstance[instanceID]; ok {
+				groupSummary.RegisteredNodes++
+
+				continue
+			}
+			missing.Insert(instanceID)
+			since, ok := c.missingSince[instanceID]
+			if !ok {
+				since = now
+				c.missingSince[instanceID] = since
+			}
+			if now.Sub(since) >= c.options.JoinTimeout {
+				groupSummary.NotJoined = append(groupSummary.NotJoined, instanceID)
+			}
+		}
+		slices.Sort(groupSummary.NotJoined)
+		summary[group.ID] = groupSummary
+	}
+	// instances that joined or stopped running start over if they go missing again
+	maps.DeleteFunc(c.missingSince, func(instanceID string, _ time.Time) bool {
+		return !missing.Has(instanceID)
+	})
+
+	c.addNodesLeftGroup(summary, groups, nodes)
+
+	return summary
+}
+
+// reported reports whether the group is summarized, either because it is configured
+// or, when no groups are configured, because it has nodes in the cluster.
+func (c *Controller) reported(group *Group, nodes []*v1.Node, nodeByInstance map[string]*v1.Node) bool {
+	if len(c.options.Groups) > 0 {
+		return slices.Contains(c.options.Groups, group.ID) || slices.Contains(c.options.Groups, group.Name)
+	}
+
+	for _, instanceID := range slices.Concat(group.RunningInstances, group.InactiveInstances) {
+		if _, ok := nodeByInstance[instanceID]; ok {
+			return true
+		}
+	}
+
+	return slices.ContainsFunc(nodes, func(node *v1.Node) bool {
+		return node.Labels[c.groupIDLabel] == group.ID
+	})
+}
+
+// addNodesLeftGroup adds the nodes labeled with a group whose instance is not in it
+// anymore. Groups that no longer exist are added to the summary when they are reported.
+func (c *Controller) addNodesLeftGroup(summary map[string]*GroupSummary, groups []Group, nodes []*v1.Node) {
+	members := make(map[string]sets.Set[string], len(groups))
+	for index := range groups {
+		members[groups[index].ID] = sets.New(slices.Concat(groups[index].RunningInstances,
+			groups[index].InactiveInstances)...)
+	}
+
+	for _, node := range nodes {
+		groupID := node.Labels[c.groupIDLabel]
+		if groupID == "" {
+			continue
+		}
+		instanceID := strings.TrimPrefix(node.Spec.ProviderID, providerPrefix)
+		if members[groupID].Has(instanceID) {
+			continue
+		}
+
+		groupSummary, ok := summary[groupID]
+		if !ok {
+			if _, exists := members[groupID]; exists ||
+				(len(c.options.Groups) > 0 && !slices.Contains(c.options.Groups, groupID)) {
+				continue
+			}
+			groupSummary = &GroupSummary{}
+			summary[groupID] = groupSummary
+		}
+		groupSummary.LeftGroup = append(groupSummary.LeftGroup, node.Name)
+	}
+
+	for _, groupSummary := range summary {
+		slices.Sort(groupSummary.LeftGroup)
+	}
+}
+
+// writeSummary writes the summary to the ConfigMap, one key per group ID.
+func (c *Controller) writeSummary(ctx context.Context, summary map[string]*GroupSummary) error {
+	data := make(map[string]string, len(summary))
+	for groupID, groupSummary := range summary {
+		value, err := yaml.Marshal(groupSummary)
+		if err != nil {
+			return fmt.Errorf("failed to marshal summary of instance group %s: %w", groupID, err)
+		}
+		data[groupID] = string(value)
+	}
+
+	configMaps := c.kubeClient.CoreV1().ConfigMaps(c.options.SummaryNamespace)
+	configMap, err := configMaps.Get(ctx, c.options.SummaryName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      c.options.SummaryName,
+				Namespace: c.options.SummaryNamespace,
+				Labels:    map[string]string{managedByLabel: managedBy},
+			},
+			Data: data,
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create instance group summary: %w", err)
+		}
+
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get instance group summary: %w", err)
+	}
+	if maps.Equal(configMap.Data, data) {
+		return nil
+	}
+
+	configMap = configMap.DeepCopy()
+	configMap.Data = data
+	if _, err := configMaps.Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update instance group summary: %w", err)
+	}
+
+	return nil
+}
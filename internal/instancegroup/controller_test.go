@@ -0,0 +1,243 @@
+package instancegroup_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instancegroup"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	cloudprovider "k8s.io/cloud-provider"
+	fakecloud "k8s.io/cloud-provider/fake"
+	"sigs.k8s.io/yaml"
+)
+
+const groupLabel = "crusoe.ai/instance.group.id"
+
+// groupCloud is a fake cloud provider listing instance groups.
+type groupCloud struct {
+	*fakecloud.Cloud
+
+	mu     sync.Mutex
+	groups []instancegroup.Group
+}
+
+func (c *groupCloud) InstancesV2() (cloudprovider.InstancesV2, bool) { return c, true }
+
+func (c *groupCloud) GroupIDLabel() (string, bool) { return groupLabel, true }
+
+func (c *groupCloud) InstanceGroups(context.Context) ([]instancegroup.Group, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.groups, nil
+}
+
+func (c *groupCloud) setGroups(groups []instancegroup.Group) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.groups = groups
+}
+
+func groupNode(name, instanceID, groupID string) *v1.Node {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1.NodeSpec{ProviderID: "crusoe://" + instanceID},
+	}
+	if groupID != "" {
+		node.Labels = map[string]string{groupLabel: groupID}
+	}
+
+	return node
+}
+
+func testGroups() []instancegroup.Group {
+	return []instancegroup.Group{
+		{
+			ID:                "group-a",
+			Name:              "gpu-a",
+			DesiredCount:      4,
+			RunningInstances:  []string{"a1", "a2", "a3"},
+			InactiveInstances: []string{"a4"},
+		},
+		{
+			ID:               "group-b",
+			Name:             "gpu-b",
+			DesiredCount:     1,
+			RunningInstances: []string{"b1"},
+		},
+	}
+}
+
+func startController(t *testing.T, options *instancegroup.Options, objects ...runtime.Object,
+) (*instancegroup.Controller, *groupCloud, *fake.Clientset) {
+	t.Helper()
+
+	kubeClient := fake.NewClientset(objects...)
+	factory := informers.NewSharedInformerFactory(kubeClient, 0)
+	cloud := &groupCloud{Cloud: &fakecloud.Cloud{}, groups: testGroups()}
+	controller, err := instancegroup.NewController(factory.Core().V1().Nodes(), kubeClient, cloud, options)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+
+	return controller, cloud, kubeClient
+}
+
+func readSummary(t *testing.T, kubeClient *fake.Clientset) map[string]instancegroup.GroupSummary {
+	t.Helper()
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(context.Background(),
+		"crusoe-instance-groups", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	summary := make(map[string]instancegroup.GroupSummary, len(configMap.Data))
+	for groupID, value := range configMap.Data {
+		var groupSummary instancegroup.GroupSummary
+		require.NoError(t, yaml.Unmarshal([]byte(value), &groupSummary))
+		summary[groupID] = groupSummary
+	}
+
+	return summary
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+
+	options := instancegroup.NewOptions()
+	options.JoinTimeout = 0
+	controller, _, kubeClient := startController(t, options,
+		groupNode("node-a1", "a1", "group-a"),
+		groupNode("node-a2", "a2", "group-a"),
+		groupNode("node-a4", "a4", "group-a"),
+		// the instance was moved out of its group
+		groupNode("node-x", "x1", "group-a"),
+		// the group of the instance was deleted
+		groupNode("node-y", "y1", "group-deleted"),
+	)
+
+	require.NoError(t, controller.Sync(context.Background()))
+	require.Equal(t, map[string]instancegroup.GroupSummary{
+		"group-a": {
+			Name:             "gpu-a",
+			DesiredCount:     4,
+			RunningInstances: 3,
+			RegisteredNodes:  3,
+			NotJoined:        []string{"a3"},
+			LeftGroup:        []string{"node-x"},
+		},
+		"group-deleted": {
+			LeftGroup: []string{"node-y"},
+		},
+	}, readSummary(t, kubeClient))
+}
+
+func TestSyncJoinTimeout(t *testing.T) {
+	t.Parallel()
+
+	options := instancegroup.NewOptions()
+	options.JoinTimeout = time.Hour
+	controller, cloud, kubeClient := startController(t, options, groupNode("node-a1", "a1", "group-a"))
+
+	require.NoError(t, controller.Sync(context.Background()))
+	require.Empty(t, readSummary(t, kubeClient)["group-a"].NotJoined)
+
+	// the summary is updated when the groups change
+	groups := testGroups()
+	groups[0].DesiredCount = 1
+	groups[0].RunningInstances = []string{"a1"}
+	cloud.setGroups(groups)
+	require.NoError(t, controller.Sync(context.Background()))
+	require.Equal(t, instancegroup.GroupSummary{
+		Name:             "gpu-a",
+		DesiredCount:     1,
+		RunningInstances: 1,
+		RegisteredNodes:  1,
+	}, readSummary(t, kubeClient)["group-a"])
+}
+
+func TestSyncConfiguredGroups(t *testing.T) {
+	t.Parallel()
+
+	options := instancegroup.NewOptions()
+	options.JoinTimeout = 0
+	options.Groups = []string{"gpu-b"}
+	controller, _, kubeClient := startController(t, options, groupNode("node-a1", "a1", "group-a"))
+
+	// groups are reported even when none of their instances joined
+	require.NoError(t, controller.Sync(context.Background()))
+	require.Equal(t, map[string]instancegroup.GroupSummary{
+		"group-b": {
+			Name:             "gpu-b",
+			DesiredCount:     1,
+			RunningInstances: 1,
+			NotJoined:        []string{"b1"},
+		},
+	}, readSummary(t, kubeClient))
+}
+
+func TestOptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, instancegroup.NewOptions().Validate())
+
+	options := instancegroup.NewOptions()
+	options.SyncPeriod = 0
+	require.ErrorIs(t, options.Validate(), instancegroup.ErrInvalidOptions)
+
+	options = instancegroup.NewOptions()
+	options.GroupIDLabel = "not a label"
+	require.ErrorIs(t, options.Validate(), instancegroup.ErrInvalidOptions)
+
+	_, err := instancegroup.NewController(nil, fake.NewClientset(), &fakecloud.Cloud{}, instancegroup.NewOptions())
+	require.ErrorIs(t, err, instancegroup.ErrInstanceGroupsNotSupported)
+}
+
+// unlabeledGroupCloud is a fake cloud provider listing instance groups without labeling nodes with them.
+type unlabeledGroupCloud struct {
+	*fakecloud.Cloud
+}
+
+func (c *unlabeledGroupCloud) InstancesV2() (cloudprovider.InstancesV2, bool) { return c, true }
+
+func (c *unlabeledGroupCloud) InstanceGroups(context.Context) ([]instancegroup.Group, error) {
+	return testGroups(), nil
+}
+
+func TestGroupIDLabel(t *testing.T) {
+	t.Parallel()
+
+	// the group ID label defaults to the label set by the cloud provider
+	cloud := &unlabeledGroupCloud{Cloud: &fakecloud.Cloud{}}
+	factory := informers.NewSharedInformerFactory(fake.NewClientset(), 0)
+	_, err := instancegroup.NewController(factory.Core().V1().Nodes(), fake.NewClientset(), cloud,
+		instancegroup.NewOptions())
+	require.ErrorIs(t, err, instancegroup.ErrNoGroupIDLabel)
+
+	// a configured label is used instead of the one set by the cloud provider
+	options := instancegroup.NewOptions()
+	options.Groups = []string{"group-b"}
+	options.GroupIDLabel = "example.com/group"
+	controller, _, kubeClient := startController(t, options,
+		// the instance is not in the group it is labeled with
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-x", Labels: map[string]string{"example.com/group": "group-b"}},
+			Spec:       v1.NodeSpec{ProviderID: "crusoe://x1"},
+		},
+		groupNode("node-y", "y1", "group-b"),
+	)
+
+	require.NoError(t, controller.Sync(context.Background()))
+	require.Equal(t, map[string]instancegroup.GroupSummary{
+		"group-b": {Name: "gpu-b", DesiredCount: 1, RunningInstances: 1, LeftGroup: []string{"node-x"}},
+	}, readSummary(t, kubeClient))
+}
@@ -0,0 +1,96 @@
+package instancegroup
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	metricsNamespace = "crusoe"
+	metricsSubsystem = "instance_group"
+)
+
+//nolint:gochecknoglobals // metrics are registered once on the global registry
+var (
+	desiredInstances = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "desired_instances",
+			Help:           "Desired number of instances of the instance group.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"group"},
+	)
+
+	runningInstances = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "running_instances",
+			Help:           "Number of running instances of the instance group.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"group"},
+	)
+
+	registeredNodes = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "registered_nodes",
+			Help:           "Number of nodes whose instance is in the instance group.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"group"},
+	)
+
+	notJoinedInstances = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "not_joined_instances",
+			Help:           "Number of running instances of the instance group without a node after the join timeout.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"group"},
+	)
+
+	nodesLeftGroup = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "nodes_left_group",
+			Help:           "Number of nodes labeled with the instance group whose instance is no longer in it.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"group"},
+	)
+
+	metricRegistration sync.Once
+)
+
+// registerMetrics registers the metrics that are to be monitored.
+func registerMetrics() {
+	metricRegistration.Do(func() {
+		legacyregistry.MustRegister(desiredInstances, runningInstances, registeredNodes, notJoinedInstances,
+			nodesLeftGroup)
+	})
+}
+
+// recordMetrics replaces the gauges with the summary, dropping groups that are gone.
+func recordMetrics(summary map[string]*GroupSummary) {
+	for _, gauge := range []*metrics.GaugeVec{desiredInstances, runningInstances, registeredNodes,
+		notJoinedInstances, nodesLeftGroup} {
+		gauge.Reset()
+	}
+	for groupID, group := range summary {
+		desiredInstances.WithLabelValues(groupID).Set(float64(group.DesiredCount))
+		runningInstances.WithLabelValues(groupID).Set(float64(group.RunningInstances))
+		registeredNodes.WithLabelValues(groupID).Set(float64(group.RegisteredNodes))
+		notJoinedInstances.WithLabelValues(groupID).Set(float64(len(group.NotJoined)))
+		nodesLeftGroup.WithLabelValues(groupID).Set(float64(len(group.LeftGroup)))
+	}
+}
@@ -0,0 +1,88 @@
+package instancegroup
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// ControllerName is the name the controller is enabled with in --controllers.
+	ControllerName = "crusoe-instance-group"
+
+	defaultSyncPeriod  = time.Minute
+	defaultJoinTimeout = 15 * time.Minute
+	defaultSummaryName = "crusoe-instance-groups"
+)
+
+var ErrInvalidOptions = errors.New("invalid instance group controller options")
+
+// Options are the command line options of the instance group controller.
+type Options struct {
+	// SyncPeriod is how often instance groups are compared with the nodes.
+	SyncPeriod time.Duration
+	// JoinTimeout is how long a running instance of a group can be without a node before
+	// it is reported as not joined.
+	JoinTimeout time.Duration
+	// Groups are the IDs or names of the instance groups to report on. When empty, the
+	// groups that have at least one node in the cluster are reported on.
+	Groups []string
+	// GroupIDLabel is the node label holding the ID of the group the node's instance was
+	// in when the node was initialized. When empty, it is the label the cloud provider
+	// sets, under the label prefix of the cloud config.
+	GroupIDLabel string
+	// SummaryNamespace and SummaryName locate the ConfigMap the summary is written to.
+	SummaryNamespace string
+	SummaryName      string
+}
+
+// NewOptions returns Options with default values.
+func NewOptions() *Options {
+	return &Options{
+		SyncPeriod:       defaultSyncPeriod,
+		JoinTimeout:      defaultJoinTimeout,
+		SummaryNamespace: metav1.NamespaceSystem,
+		SummaryName:      defaultSummaryName,
+	}
+}
+
+// AddFlags adds the instance group controller flags to the flag set.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(&o.SyncPeriod, "instance-group-sync-period", o.SyncPeriod,
+		"How often the instance group controller compares Crusoe instance groups with the nodes.")
+	fs.DurationVar(&o.JoinTimeout, "instance-group-join-timeout", o.JoinTimeout,
+		"How long a running instance of an instance group can be without a node before it is reported as not joined.")
+	fs.StringSliceVar(&o.Groups, "instance-group-groups", o.Groups,
+		"IDs or names of the instance groups to report on. Defaults to the groups with at least one node.")
+	fs.StringVar(&o.GroupIDLabel, "instance-group-id-label", o.GroupIDLabel,
+		"Node label holding the ID of the instance group of the node's instance. Defaults to the "+
+			"<prefix>/instance.group.id label of the label prefix in the cloud config.")
+	fs.StringVar(&o.SummaryNamespace, "instance-group-summary-namespace", o.SummaryNamespace,
+		"Namespace of the ConfigMap the instance group summary is written to.")
+	fs.StringVar(&o.SummaryName, "instance-group-summary-name", o.SummaryName,
+		"Name of the ConfigMap the instance group summary is written to.")
+}
+
+// Validate returns an error if the options cannot be used.
+func (o *Options) Validate() error {
+	if o.SyncPeriod <= 0 {
+		return fmt.Errorf("%w: --instance-group-sync-period must be positive", ErrInvalidOptions)
+	}
+	if o.JoinTimeout < 0 {
+		return fmt.Errorf("%w: --instance-group-join-timeout must not be negative", ErrInvalidOptions)
+	}
+	if o.GroupIDLabel != "" && len(validation.IsQualifiedName(o.GroupIDLabel)) > 0 {
+		return fmt.Errorf("%w: --instance-group-id-label %q is not a label key", ErrInvalidOptions, o.GroupIDLabel)
+	}
+	if len(validation.IsDNS1123Label(o.SummaryNamespace)) > 0 ||
+		len(validation.IsDNS1123Subdomain(o.SummaryName)) > 0 {
+		return fmt.Errorf("%w: invalid summary ConfigMap %s/%s", ErrInvalidOptions, o.SummaryNamespace,
+			o.SummaryName)
+	}
+
+	return nil
+}
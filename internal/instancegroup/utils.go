@@ -0,0 +1,39 @@
+package instancegroup
+
+import (
+	"context"
+
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	"k8s.io/cloud-provider/app/config"
+	controllermanagerapp "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// StartInstanceGroupControllerWrapper is used to take cloud config as input and start
+// the instance group controller with the options.
+func (o *Options) StartInstanceGroupControllerWrapper(initContext app.ControllerInitContext,
+	completedConfig *config.CompletedConfig,
+	cloud cloudprovider.Interface,
+) app.InitFunc {
+	return func(ctx context.Context,
+		controllerContext controllermanagerapp.ControllerContext,
+	) (controller.Interface, bool, error) {
+		instanceGroupController, err := NewController(
+			completedConfig.SharedInformers.Core().V1().Nodes(),
+			completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+			cloud,
+			o,
+		)
+		if err != nil {
+			klog.ErrorS(err, "Failed to start instance group controller")
+
+			return nil, false, nil
+		}
+
+		go instanceGroupController.Run(ctx, controllerContext.ControllerManagerMetrics)
+
+		return nil, true, nil
+	}
+}
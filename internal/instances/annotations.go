@@ -0,0 +1,158 @@
+package instances
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// Instance detail annotations, prefixed with the configured label prefix. They are
+// kept up to date every time the cloud node controller fetches instance metadata.
+// The v1alpha5 instance does not carry its image or SSH key names, so those are not published.
+const (
+	AnnotationInstanceName              = "instance.name"
+	AnnotationInstanceType              = "instance.type"
+	AnnotationInstanceLocation          = "instance.location"
+	AnnotationInstanceProjectID         = "instance.projectId"
+	AnnotationInstanceCreatedAt         = "instance.createdAt"
+	AnnotationInstanceBillingType       = "instance.billingType"
+	AnnotationInstanceMaintenancePolicy = "instance.maintenancePolicy"
+	AnnotationInstanceReservationID     = "instance.reservationId"
+	AnnotationInstanceNvlinkDomainID    = "instance.nvlinkDomainId"
+	AnnotationInstanceDisks             = "instance.disks"
+	AnnotationInstanceNetworkInterfaces = "instance.networkInterfaces"
+)
+
+type diskAnnotation struct {
+	ID             string `json:"id"`
+	Name           string `json:"name,omitempty"`
+	Type           string `json:"type,omitempty"`
+	Mode           string `json:"mode,omitempty"`
+	AttachmentType string `json:"attachmentType,omitempty"`
+}
+
+type networkInterfaceAnnotation struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name,omitempty"`
+	Network    string   `json:"network,omitempty"`
+	Subnet     string   `json:"subnet,omitempty"`
+	MacAddress string   `json:"macAddress,omitempty"`
+	PrivateIPs []string `json:"privateIPs,omitempty"`
+	PublicIPs  []string `json:"publicIPs,omitempty"`
+}
+
+// instanceAnnotations returns the detail annotations for the instance. Details the
+// instance does not have are returned with an empty value so that stale annotations
+// get removed from the node.
+func instanceAnnotations(prefix string, instance *crusoeapi.InstanceV1Alpha5) (map[string]string, error) {
+	annotations := map[string]string{
+		AnnotationInstanceName:              instance.Name,
+		AnnotationInstanceType:              instance.Type_,
+		AnnotationInstanceLocation:          instance.Location,
+		AnnotationInstanceProjectID:         instance.ProjectId,
+		AnnotationInstanceCreatedAt:         instance.CreatedAt,
+		AnnotationInstanceBillingType:       instance.BillingType,
+		AnnotationInstanceMaintenancePolicy: instance.MaintenancePolicy,
+		AnnotationInstanceReservationID:     instance.ReservationId,
+		AnnotationInstanceNvlinkDomainID:    instance.NvlinkDomainId,
+		AnnotationInstanceDisks:             "",
+		AnnotationInstanceNetworkInterfaces: "",
+	}
+
+	if len(instance.Disks) > 0 {
+		disks := make([]diskAnnotation, 0, len(instance.Disks))
+		for _, disk := range instance.Disks {
+			disks = append(disks, diskAnnotation{
+				ID:             disk.Id,
+				Name:           disk.Name,
+				Type:           disk.Type_,
+				Mode:           disk.Mode,
+				AttachmentType: disk.AttachmentType,
+			})
+		}
+		value, err := json.Marshal(disks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal disks annotation: %w", err)
+		}
+		annotations[AnnotationInstanceDisks] = string(value)
+	}
+
+	if len(instance.NetworkInterfaces) > 0 {
+		nics := make([]networkInterfaceAnnotation, 0, len(instance.NetworkInterfaces))
+		for _, nic := range instance.NetworkInterfaces {
+			nicAnnotation := networkInterfaceAnnotation{
+				ID:         nic.Id,
+				Name:       nic.Name,
+				Network:    nic.Network,
+				Subnet:     nic.Subnet,
+				MacAddress: nic.MacAddress,
+			}
+			for _, ip := range nic.Ips {
+				if ip.PrivateIpv4 != nil && ip.PrivateIpv4.Address != "" {
+					nicAnnotation.PrivateIPs = append(nicAnnotation.PrivateIPs, ip.PrivateIpv4.Address)
+				}
+				if ip.PublicIpv4 != nil && ip.PublicIpv4.Address != "" {
+					nicAnnotation.PublicIPs = append(nicAnnotation.PublicIPs, ip.PublicIpv4.Address)
+				}
+			}
+			nics = append(nics, nicAnnotation)
+		}
+		value, err := json.Marshal(nics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal network interfaces annotation: %w", err)
+		}
+		annotations[AnnotationInstanceNetworkInterfaces] = string(value)
+	}
+
+	prefixed := make(map[string]string, len(annotations))
+	for name, value := range annotations {
+		prefixed[prefix+"/"+name] = value
+	}
+
+	return prefixed, nil
+}
+
+// reconcileNodeAnnotations sets the given annotations on the node. Annotations with an
+// empty value are removed. The API call is skipped when the node is already up to date.
+func (i *Instances) reconcileNodeAnnotations(ctx context.Context, node *v1.Node, annotations map[string]string) error {
+	if i.kubeClient == nil || len(annotations) == 0 {
+		return nil
+	}
+	changed := make(map[string]any)
+	for key, value := range annotations {
+		current, ok := node.Annotations[key]
+		switch {
+		case value == "" && ok:
+			changed[key] = nil
+		case value != "" && (!ok || current != value):
+			changed[key] = value
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": changed,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal annotations patch: %w", err)
+	}
+	_, err = i.kubeClient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch annotations on node %s: %w", node.Name, err)
+	}
+	klog.V(4).InfoS("Updated annotations", "node", klog.KObj(node), "annotations", slices.Sorted(maps.Keys(changed)))
+
+	return nil
+}
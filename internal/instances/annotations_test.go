@@ -0,0 +1,49 @@
+package instances_test
+
+import (
+	"context"
+	"testing"
+
+	v1alpha5 "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestInstanceMetadataAnnotations(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	node := testNode()
+	node.Annotations = map[string]string{
+		"crusoe.ai/instance.reservationId": "old-reservation",
+		"unrelated":                        "kept",
+	}
+	kubeClient := fake.NewClientset(node)
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstances(mockClient)
+	instanceService.SetKubeClient(kubeClient)
+
+	instance := testInstance()
+	instance.CreatedAt = "2024-01-01T00:00:00Z"
+	instance.Disks = []v1alpha5.AttachedDiskV1Alpha5{{Id: "disk-1", Name: "data", Mode: "read-write"}}
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(instance, nil, nil)
+
+	_, err := instanceService.InstanceMetadata(context.Background(), node)
+	require.NoError(t, err)
+
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.Background(), TESTNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "2024-01-01T00:00:00Z", updated.Annotations["crusoe.ai/instance.createdAt"])
+	require.Equal(t, TESTNodeName, updated.Annotations["crusoe.ai/instance.name"])
+	require.JSONEq(t, `[{"id":"disk-1","name":"data","mode":"read-write"}]`,
+		updated.Annotations["crusoe.ai/instance.disks"])
+	require.JSONEq(t, `[{"id":"","privateIPs":["10.0.0.1"],"publicIPs":["192.168.0.1"]}]`,
+		updated.Annotations["crusoe.ai/instance.networkInterfaces"])
+	require.NotContains(t, updated.Annotations, "crusoe.ai/instance.reservationId")
+	require.Equal(t, "kept", updated.Annotations["unrelated"])
+}
@@ -0,0 +1,45 @@
+package instances
+
+import "fmt"
+
+// Config holds the cloud config settings used by Instances.
+type Config struct {
+	Labels LabelConfig `json:"labels,omitempty"`
+	// DisableInstanceAnnotations stops the <prefix>/instance.* detail annotations from being written to nodes.
+	DisableInstanceAnnotations bool `json:"disableInstanceAnnotations,omitempty"`
+	// TaintRules are applied to nodes on initialization based on instance attributes.
+	TaintRules []TaintRule `json:"taintRules,omitempty"`
+	// InstanceNotFound controls when missing instances are reported as deleted or shut down.
+	InstanceNotFound NotFoundConfig `json:"instanceNotFound,omitempty"`
+}
+
+// DefaultConfig returns the Config used when no cloud config is provided.
+func DefaultConfig() Config {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	return cfg
+}
+
+// ApplyDefaults fills in unset fields with their default values.
+func (c *Config) ApplyDefaults() {
+	c.Labels.applyDefaults()
+	c.InstanceNotFound.applyDefaults()
+}
+
+// Validate returns an error if the config cannot be used.
+func (c *Config) Validate() error {
+	if err := c.Labels.validate(); err != nil {
+		return err
+	}
+	if err := c.InstanceNotFound.validate(); err != nil {
+		return err
+	}
+	for index := range c.TaintRules {
+		if err := c.TaintRules[index].validate(); err != nil {
+			return fmt.Errorf("taint rule %d: %w", index, err)
+		}
+	}
+
+	return nil
+}
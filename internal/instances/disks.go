@@ -0,0 +1,35 @@
+package instances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DiskAttached reports whether the Crusoe disk is still attached to the instance with the
+// provider ID. Disks that no longer exist are not attached.
+func (i *Instances) DiskAttached(ctx context.Context, diskID, providerID string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "DiskAttached",
+		attribute.String(attributeDiskID, diskID), attribute.String(attributeProviderID, providerID))
+	defer func() { endSpan(span, err) }()
+
+	disk, err := i.apiClient.GetDisk(ctx, diskID)
+	if errors.Is(err, client.ErrDiskNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get disk %s: %w", diskID, err)
+	}
+
+	instanceID := getInstanceIDFromProviderID(providerID)
+	for _, attachment := range disk.AttachedTo {
+		if attachment.VmId == instanceID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,39 @@
+package instances_test
+
+import (
+	"context"
+	"testing"
+
+	v1alpha5 "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskAttached(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstances(mockClient)
+	mockClient.EXPECT().GetDisk(gomock.Any(), "attached").Return(&v1alpha5.DiskV1Alpha5{
+		Id:         "attached",
+		AttachedTo: []v1alpha5.VmAttachmentV1Alpha5{{VmId: TESTInstanceID}},
+	}, nil).Times(2)
+	mockClient.EXPECT().GetDisk(gomock.Any(), "deleted").Return(nil, client.ErrDiskNotFound)
+
+	attached, err := instanceService.DiskAttached(context.Background(), "attached", ProviderIDPrefix+TESTInstanceID)
+	require.NoError(t, err)
+	require.True(t, attached)
+
+	attached, err = instanceService.DiskAttached(context.Background(), "attached", ProviderIDPrefix+"other-instance")
+	require.NoError(t, err)
+	require.False(t, attached)
+
+	attached, err = instanceService.DiskAttached(context.Background(), "deleted", ProviderIDPrefix+TESTInstanceID)
+	require.NoError(t, err)
+	require.False(t, attached)
+}
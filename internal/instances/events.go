@@ -0,0 +1,27 @@
+package instances
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	instanceLookupFailedEvent    = "InstanceLookupFailed"
+	ambiguousInstanceNameEvent   = "AmbiguousInstanceName"
+	missingNodeAddressEvent      = "MissingNodeAddress"
+	ibPartitionLookupFailedEvent = "IBPartitionLookupFailed"
+	providerIDCorrectedEvent     = "ProviderIDCorrected"
+)
+
+// SetEventRecorder sets the recorder used to report lookup problems and identity
+// corrections as events on the Node. Events are not emitted until it is set.
+func (i *Instances) SetEventRecorder(recorder record.EventRecorder) {
+	i.recorder = recorder
+}
+
+func (i *Instances) eventf(node *v1.Node, eventType, reason, messageFmt string, args ...any) {
+	if i.recorder == nil || node == nil {
+		return
+	}
+	i.recorder.Eventf(node, eventType, reason, messageFmt, args...)
+}
@@ -0,0 +1,158 @@
+package instances_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v1alpha5 "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const otherInstanceID = "6f1f0b55-4d8f-4c0e-9a55-0a6c8a1b2c3d"
+
+func mismatchedNode() *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: TESTNodeName},
+		Spec:       v1.NodeSpec{ProviderID: ProviderIDPrefix + otherInstanceID},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: TESTInstanceID},
+		},
+	}
+}
+
+func privateOnlyInstance() *v1alpha5.InstanceV1Alpha5 {
+	return &v1alpha5.InstanceV1Alpha5{
+		Id:   TESTInstanceID,
+		Name: TESTNodeName,
+		NetworkInterfaces: []v1alpha5.NetworkInterface{{
+			Ips: []v1alpha5.IpAddresses{{
+				PrivateIpv4: &v1alpha5.PrivateIpv4Address{Address: "10.0.0.1"},
+			}},
+		}},
+		Location: TestLocation,
+	}
+}
+
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestInstanceMetadataEvents(t *testing.T) {
+	t.Parallel()
+
+	withoutAddress := privateOnlyInstance()
+	withoutAddress.NetworkInterfaces = nil
+
+	tests := []struct {
+		name       string
+		byName     func() (*v1alpha5.InstanceV1Alpha5, error)
+		byID       *v1alpha5.InstanceV1Alpha5
+		byIDErr    error
+		wantErr    error
+		wantEvents []string
+	}{
+		{
+			name:   "provider ID corrected",
+			byName: func() (*v1alpha5.InstanceV1Alpha5, error) { return privateOnlyInstance(), nil },
+			byID:   privateOnlyInstance(),
+			wantEvents: []string{fmt.Sprintf("Normal ProviderIDCorrected ProviderID %s does not match SystemUUID "+
+				"%s, using instance %s found by name", ProviderIDPrefix+otherInstanceID, TESTInstanceID,
+				TESTInstanceID)},
+		},
+		{
+			name: "ambiguous name",
+			byName: func() (*v1alpha5.InstanceV1Alpha5, error) {
+				return nil, client.ErrAmbiguousInstanceName
+			},
+			wantErr: client.ErrAmbiguousInstanceName,
+			wantEvents: []string{fmt.Sprintf("Warning AmbiguousInstanceName Cannot identify the instance of node "+
+				"%s: %s", TESTNodeName, client.ErrAmbiguousInstanceName)},
+		},
+		{
+			name:    "instance lookup failed",
+			byName:  func() (*v1alpha5.InstanceV1Alpha5, error) { return privateOnlyInstance(), nil },
+			byIDErr: client.ErrInstanceNotFound,
+			wantErr: client.ErrInstanceNotFound,
+			wantEvents: []string{
+				"Normal ProviderIDCorrected",
+				fmt.Sprintf("Warning InstanceLookupFailed Failed to look up instance %s: %s", TESTInstanceID,
+					client.ErrInstanceNotFound),
+			},
+		},
+		{
+			name:    "missing address",
+			byName:  func() (*v1alpha5.InstanceV1Alpha5, error) { return privateOnlyInstance(), nil },
+			byID:    withoutAddress,
+			wantErr: instances.ErrNoNetworkInterface,
+			wantEvents: []string{
+				"Normal ProviderIDCorrected",
+				fmt.Sprintf("Warning MissingNodeAddress Instance %s has no usable address: %s", TESTInstanceID,
+					instances.ErrNoNetworkInterface),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClient := mock_client.NewMockApiClient(ctrl)
+			mockClient.EXPECT().GetInstanceByName(gomock.Any(), TESTNodeName).DoAndReturn(
+				func(context.Context, string) (*v1alpha5.InstanceV1Alpha5, error) { return test.byName() })
+			if test.byID != nil || test.byIDErr != nil {
+				mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(test.byID, nil, test.byIDErr)
+			}
+			recorder := record.NewFakeRecorder(10)
+			instanceService := instances.NewCrusoeInstances(mockClient)
+			instanceService.SetEventRecorder(recorder)
+
+			_, err := instanceService.InstanceMetadata(context.Background(), mismatchedNode())
+			if test.wantErr != nil {
+				require.ErrorIs(t, err, test.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			events := drainEvents(recorder)
+			require.Len(t, events, len(test.wantEvents))
+			for index, want := range test.wantEvents {
+				require.Contains(t, events[index], want)
+			}
+		})
+	}
+}
+
+func TestNodeAddressesWithoutPublicIP(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(privateOnlyInstance(), nil, nil)
+	instanceService := instances.NewCrusoeInstances(mockClient)
+
+	addresses, err := instanceService.NodeAddressesByProviderID(context.Background(), ProviderIDPrefix+TESTInstanceID)
+	require.NoError(t, err)
+	require.Equal(t, []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeHostName, Address: fmt.Sprintf("%s.%s.compute.internal", TESTNodeName, TestLocation)},
+	}, addresses)
+}
@@ -0,0 +1,44 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instancegroup"
+)
+
+// GroupIDLabel returns the label nodes are labeled with the ID of their instance group,
+// false when the instance labels are disabled.
+func (i *Instances) GroupIDLabel() (string, bool) {
+	return i.config.Labels.Key(LabelGroupInstance, LabelInstanceGroupID)
+}
+
+// InstanceGroups lists the instance groups of the project.
+func (i *Instances) InstanceGroups(ctx context.Context) (_ []instancegroup.Group, err error) {
+	ctx, span := startSpan(ctx, "InstanceGroups")
+	defer func() { endSpan(span, err) }()
+
+	crusoeGroups, err := i.apiClient.ListInstanceGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instance groups: %w", err)
+	}
+
+	groups := make([]instancegroup.Group, 0, len(crusoeGroups))
+	for index := range crusoeGroups {
+		crusoeGroup := &crusoeGroups[index]
+		running := crusoeGroup.ActiveInstances
+		if len(running) == 0 {
+			// older API versions only return the deprecated list of running instances
+			running = crusoeGroup.Instances
+		}
+		groups = append(groups, instancegroup.Group{
+			ID:                crusoeGroup.Id,
+			Name:              crusoeGroup.Name,
+			DesiredCount:      crusoeGroup.DesiredCount,
+			RunningInstances:  running,
+			InactiveInstances: crusoeGroup.InactiveInstances,
+		})
+	}
+
+	return groups, nil
+}
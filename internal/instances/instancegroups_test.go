@@ -0,0 +1,64 @@
+package instances_test
+
+import (
+	"context"
+	"testing"
+
+	v1alpha5 "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instancegroup"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceGroups(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstances(mockClient)
+	mockClient.EXPECT().ListInstanceGroups(gomock.Any()).Return([]v1alpha5.InstanceGroup{
+		{
+			Id:                "group-a",
+			Name:              "gpu-a",
+			DesiredCount:      2,
+			ActiveInstances:   []string{"a1"},
+			InactiveInstances: []string{"a2"},
+		},
+		{
+			Id:           "group-b",
+			Name:         "gpu-b",
+			DesiredCount: 1,
+			Instances:    []string{"b1"},
+		},
+	}, nil)
+
+	groups, err := instanceService.InstanceGroups(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []instancegroup.Group{
+		{ID: "group-a", Name: "gpu-a", DesiredCount: 2, RunningInstances: []string{"a1"}, InactiveInstances: []string{"a2"}},
+		{ID: "group-b", Name: "gpu-b", DesiredCount: 1, RunningInstances: []string{"b1"}},
+	}, groups)
+}
+
+func TestGroupIDLabel(t *testing.T) {
+	t.Parallel()
+
+	label, ok := instances.NewCrusoeInstances(nil).GroupIDLabel()
+	require.True(t, ok)
+	require.Equal(t, "crusoe.ai/instance.group.id", label)
+
+	label, ok = instances.NewCrusoeInstancesWithConfig(nil, instances.Config{
+		Labels: instances.LabelConfig{Prefix: "example.com"},
+	}).GroupIDLabel()
+	require.True(t, ok)
+	require.Equal(t, "example.com/instance.group.id", label)
+
+	// nodes are not labeled with their instance group when the instance labels are disabled
+	_, ok = instances.NewCrusoeInstancesWithConfig(nil, instances.Config{
+		Labels: instances.LabelConfig{Groups: []string{instances.LabelGroupIB}},
+	}).GroupIDLabel()
+	require.False(t, ok)
+}
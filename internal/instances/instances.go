@@ -4,14 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"strings"
-	"sync"
 	"time"
 
 	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
 	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	"go.opentelemetry.io/otel/attribute"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
 )
@@ -21,15 +24,26 @@ const (
 	ProviderPrefix           = "crusoe://"
 )
 
-var ErrAssertTimeTypeFailed = errors.New("failed to assert type time.Time for firstSeen")
+var (
+	// ErrNoNetworkInterface is returned when an instance has no network interface with an IP.
+	ErrNoNetworkInterface = errors.New("instance has no network interface")
+	// ErrNoPrivateIP is returned when the primary network interface of an instance has no private IP.
+	ErrNoPrivateIP = errors.New("instance has no private IP")
+)
 
 type Instances struct {
-	nodeFirstSeen sync.Map
-	nodeShutdown  sync.Map
-	apiClient     client.APIClient
+	notFound   *notFoundTracker
+	apiClient  client.APIClient
+	kubeClient clientset.Interface
+	recorder   record.EventRecorder
+	clusterID  string
+	config     Config
 }
 
-func (i *Instances) NodeAddresses(ctx context.Context, name types.NodeName) ([]v1.NodeAddress, error) {
+func (i *Instances) NodeAddresses(ctx context.Context, name types.NodeName) (_ []v1.NodeAddress, err error) {
+	ctx, span := startSpan(ctx, "NodeAddresses", attribute.String(attributeNodeName, string(name)))
+	defer func() { endSpan(span, err) }()
+
 	currInstance, err := i.apiClient.GetInstanceByName(ctx, string(name))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get instance by name %s: %w", name, err)
@@ -38,7 +52,11 @@ func (i *Instances) NodeAddresses(ctx context.Context, name types.NodeName) ([]v
 	return getNodeAddress(currInstance)
 }
 
-func (i *Instances) NodeAddressesByProviderID(ctx context.Context, providerID string) ([]v1.NodeAddress, error) {
+func (i *Instances) NodeAddressesByProviderID(ctx context.Context, providerID string,
+) (_ []v1.NodeAddress, err error) {
+	ctx, span := startSpan(ctx, "NodeAddressesByProviderID", attribute.String(attributeProviderID, providerID))
+	defer func() { endSpan(span, err) }()
+
 	currInstance, responseBody, err := i.apiClient.GetInstanceByID(ctx, getInstanceIDFromProviderID(providerID))
 	if responseBody != nil {
 		defer responseBody.Body.Close()
@@ -50,12 +68,15 @@ func (i *Instances) NodeAddressesByProviderID(ctx context.Context, providerID st
 	if err != nil {
 		return nil, fmt.Errorf("failed to get node address for instance %s: %w", currInstance.Id, err)
 	}
-	klog.Infof("NodeAddressesByProviderID(%v) and response address %v", providerID, address)
+	klog.V(4).InfoS("Got node addresses", "providerID", providerID, "addresses", address)
 
 	return address, nil
 }
 
-func (i *Instances) InstanceID(ctx context.Context, nodeName types.NodeName) (string, error) {
+func (i *Instances) InstanceID(ctx context.Context, nodeName types.NodeName) (_ string, err error) {
+	ctx, span := startSpan(ctx, "InstanceID", attribute.String(attributeNodeName, string(nodeName)))
+	defer func() { endSpan(span, err) }()
+
 	currInstance, err := i.apiClient.GetInstanceByName(ctx, string(nodeName))
 	if err != nil {
 		return "", fmt.Errorf("failed to get instance by name %s: %w", nodeName, err)
@@ -64,17 +85,23 @@ func (i *Instances) InstanceID(ctx context.Context, nodeName types.NodeName) (st
 	return currInstance.Id, nil
 }
 
-func (i *Instances) InstanceType(ctx context.Context, name types.NodeName) (string, error) {
+func (i *Instances) InstanceType(ctx context.Context, name types.NodeName) (_ string, err error) {
+	ctx, span := startSpan(ctx, "InstanceType", attribute.String(attributeNodeName, string(name)))
+	defer func() { endSpan(span, err) }()
+
 	currInstance, err := i.apiClient.GetInstanceByName(ctx, string(name))
 	if err != nil {
 		return "", fmt.Errorf("failed to get instance by name %s: %w", name, err)
 	}
-	klog.Infof("InstanceType(%v) is %v", name, currInstance.Type_)
+	klog.V(4).InfoS("Got instance type", "node", name, "instanceType", currInstance.Type_)
 
 	return currInstance.Type_, nil
 }
 
-func (i *Instances) InstanceTypeByProviderID(ctx context.Context, providerID string) (string, error) {
+func (i *Instances) InstanceTypeByProviderID(ctx context.Context, providerID string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "InstanceTypeByProviderID", attribute.String(attributeProviderID, providerID))
+	defer func() { endSpan(span, err) }()
+
 	currInstance, responseBody, err := i.apiClient.GetInstanceByID(ctx, getInstanceIDFromProviderID(providerID))
 	if responseBody != nil {
 		defer responseBody.Body.Close()
@@ -82,7 +109,7 @@ func (i *Instances) InstanceTypeByProviderID(ctx context.Context, providerID str
 	if err != nil {
 		return "", fmt.Errorf("failed to get instance by provider ID %s: %w", providerID, err)
 	}
-	klog.Infof("InstanceTypeByProviderID(%v) is %v", providerID, currInstance.Type_)
+	klog.V(4).InfoS("Got instance type", "providerID", providerID, "instanceType", currInstance.Type_)
 
 	return currInstance.Type_, nil
 }
@@ -95,92 +122,129 @@ func (i *Instances) CurrentNodeName(_ context.Context, hostname string) (types.N
 	return types.NodeName(hostname), nil
 }
 
-func (i *Instances) InstanceShutdownByProviderID(ctx context.Context, providerID string) (bool, error) {
+func (i *Instances) InstanceShutdownByProviderID(ctx context.Context, providerID string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "InstanceShutdownByProviderID", attribute.String(attributeProviderID, providerID))
+	defer func() { endSpan(span, err) }()
+
+	return i.instanceShutdown(ctx, providerID, nil)
+}
+
+func (i *Instances) InstanceShutdown(ctx context.Context, node *v1.Node) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "InstanceShutdown", nodeAttributes(node)...)
+	defer func() { endSpan(span, err) }()
+
+	providerID, err := getProviderID(ctx, node, i)
+	if err != nil {
+		return false, err
+	}
+
+	return i.instanceShutdown(ctx, providerID, node)
+}
+
+// instanceShutdown checks if the instance is shut down. node may be nil when it is not known,
+// in which case the not found attempts are only tracked in memory.
+func (i *Instances) instanceShutdown(ctx context.Context, providerID string, node *v1.Node) (bool, error) {
 	currInstance, responseBody, err := i.apiClient.GetInstanceByID(ctx, getInstanceIDFromProviderID(providerID))
 	if responseBody != nil {
 		defer responseBody.Body.Close()
 	}
 	if err != nil {
 		if errors.Is(err, client.ErrInstanceNotFound) {
-			return i.handleInstanceNotFoundErr(providerID, err)
+			return i.handleInstanceNotFoundErr(ctx, providerID, node, err)
 		}
 
 		return false, fmt.Errorf("failed to get instance by provider ID %s: %w", providerID, err)
 	}
-	if currInstance == nil || currInstance.State == "STATE_SHUTOFF" || currInstance.State == "STATE_SHUTDOWN" {
-		klog.Infof("Instance (%v) is Shutdown", providerID)
+	if currInstance == nil {
+		klog.V(2).InfoS("Instance is shut down", "providerID", providerID)
+
+		return true, nil
+	}
+	i.instanceFound(ctx, providerID, node)
+	lifecycle := instanceLifecycle(currInstance)
+	if lifecycle.Shutdown() {
+		klog.V(2).InfoS("Instance is shut down", "providerID", providerID, "state", currInstance.State)
 
 		return true, nil
 	}
+	if lifecycle.Transient() {
+		klog.V(2).InfoS("Instance is in a transient state, not reporting it as shut down",
+			"providerID", providerID, "state", currInstance.State)
+	}
 
 	return false, nil
 }
 
-func (i *Instances) InstanceShutdown(ctx context.Context, node *v1.Node) (bool, error) {
+func (i *Instances) InstanceExistsByProviderID(ctx context.Context, providerID string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "InstanceExistsByProviderID", attribute.String(attributeProviderID, providerID))
+	defer func() { endSpan(span, err) }()
+
+	return i.instanceExists(ctx, providerID, nil)
+}
+
+func (i *Instances) InstanceExists(ctx context.Context, node *v1.Node) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "InstanceExists", nodeAttributes(node)...)
+	defer func() { endSpan(span, err) }()
+
 	providerID, err := getProviderID(ctx, node, i)
 	if err != nil {
 		return false, err
 	}
 
-	return i.InstanceShutdownByProviderID(ctx, providerID)
+	return i.instanceExists(ctx, providerID, node)
 }
 
-//nolint:cyclop // must perform all checks before returning instance does not exist
-func (i *Instances) InstanceExistsByProviderID(ctx context.Context, providerID string) (bool, error) {
+// instanceExists checks if the instance exists. An instance that is missing from the Crusoe API
+// is reported as existing until the configured grace period has passed. node may be nil when it
+// is not known, in which case the grace period is only tracked in memory.
+func (i *Instances) instanceExists(ctx context.Context, providerID string, node *v1.Node) (bool, error) {
 	inst, responseBody, err := i.apiClient.GetInstanceByID(ctx, getInstanceIDFromProviderID(providerID))
 	if responseBody != nil {
 		defer responseBody.Body.Close()
 	}
 	if err != nil && responseBody != nil && responseBody.StatusCode != 404 {
-		klog.Errorf("Error getting instance by ID: %v", err)
-
 		return false, fmt.Errorf("failed to get instance by ID %s: %w", providerID, err)
 	}
-	klog.Infof("InstanceExistsAPI Response(%v)", responseBody)
-	currTime := time.Now()
-	firstSeen, ok := i.nodeFirstSeen.Load(providerID)
-	if !ok {
-		i.nodeFirstSeen.Store(providerID, currTime)
-		firstSeen = currTime
-	}
-	firstSeenTime, ok := firstSeen.(time.Time)
-	if !ok {
-		// update the in-memory state to current time so that we can process it in next iteration
-		i.nodeFirstSeen.Store(providerID, currTime)
-		firstSeenTime = currTime
-	}
-	timeDiff := currTime.Sub(firstSeenTime)
 	if inst == nil || (responseBody != nil && responseBody.StatusCode == 404) {
-		if timeDiff < InstanceNotFoundInterval {
-			klog.Infof("Node %v last seen: %v", providerID, timeDiff)
-			klog.Infof("Node %v not seen for less than 2 minutes", providerID)
+		gracePeriod := i.config.InstanceNotFound.GracePeriod.Duration
+		currTime := time.Now()
+		timeDiff := currTime.Sub(i.notFoundSince(ctx, providerID, node, currTime))
+		if timeDiff < gracePeriod {
+			klog.V(2).InfoS("Instance not found, within grace period", "providerID", providerID,
+				"notFoundFor", timeDiff, "gracePeriod", gracePeriod)
 
 			return true, nil
 		}
-		klog.Infof("Node %v not seen for more than 2 minutes", providerID)
+		klog.InfoS("Instance not found for longer than the grace period", "providerID", providerID,
+			"notFoundFor", timeDiff, "gracePeriod", gracePeriod)
 
 		return false, nil
 	}
-	i.nodeFirstSeen.Store(providerID, currTime)
+	i.instanceFound(ctx, providerID, node)
 
 	return true, nil
 }
 
-func (i *Instances) InstanceExists(ctx context.Context, node *v1.Node) (bool, error) {
-	providerID, err := getProviderID(ctx, node, i)
-	if err != nil {
-		return false, err
+// instanceLifecycle returns the lifecycle of the instance state, logging states that are
+// not known so that they can be added to the state model once they are documented.
+func instanceLifecycle(instance *crusoeapi.InstanceV1Alpha5) Lifecycle {
+	state := State(instance.State)
+	if !state.Known() {
+		klog.V(2).InfoS("Instance has an unknown state, treating it as transient",
+			"instanceID", instance.Id, "state", instance.State)
 	}
 
-	return i.InstanceExistsByProviderID(ctx, providerID)
+	return state.Lifecycle()
 }
 
-func (i *Instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprovider.InstanceMetadata, error) {
-	klog.Infof("Get Instance Metadata for (%v)", node.Name)
+func (i *Instances) InstanceMetadata(ctx context.Context, node *v1.Node,
+) (_ *cloudprovider.InstanceMetadata, err error) {
+	ctx, span := startSpan(ctx, "InstanceMetadata", nodeAttributes(node)...)
+	defer func() { endSpan(span, err) }()
+
+	klog.V(4).InfoS("Getting instance metadata", "node", klog.KObj(node))
 	prefixedProviderID, err := getProviderID(ctx, node, i)
 	if err != nil {
-		klog.Errorf("could not get provider ID from Crusoe Cloud %v", err)
-
 		return nil, err
 	}
 	providerID := getInstanceIDFromProviderID(prefixedProviderID)
@@ -189,69 +253,133 @@ func (i *Instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloud
 		defer responseBody.Body.Close()
 	}
 	if err != nil {
+		i.eventf(node, v1.EventTypeWarning, instanceLookupFailedEvent,
+			"Failed to look up instance %s: %v", providerID, err)
+
 		return nil, fmt.Errorf("failed to get instance by ID %s: %w", providerID, err)
 	}
-	klog.Infof("InstanceMetadata for (%v:%v)", node.Name, currInstance)
+	klog.V(4).InfoS("Got instance", "node", klog.KObj(node), "instance", loggedInstance{currInstance})
 	nodeAddress, err := getNodeAddress(currInstance)
 	if err != nil {
+		i.eventf(node, v1.EventTypeWarning, missingNodeAddressEvent,
+			"Instance %s has no usable address: %v", currInstance.Id, err)
+
 		return nil, fmt.Errorf("failed to get node address for instance %s: %w", currInstance.Id, err)
 	}
-	additionalLabels := make(map[string]string)
+	nodeLabels := newNodeLabels(i.config.Labels)
+	// the IB labels are added without a partition too, so that stale original values are removed
+	ibPartition := &crusoeapi.IbPartition{}
 	if len(currInstance.HostChannelAdapters) > 0 {
-		ibPartition, err := i.apiClient.GetIBNetwork(ctx, currInstance.ProjectId,
+		partition, err := i.apiClient.GetIBNetwork(ctx, currInstance.ProjectId,
 			currInstance.HostChannelAdapters[0].IbPartitionId)
-		klog.Infof("ibPartition: %v", ibPartition)
 		if err != nil {
+			i.eventf(node, v1.EventTypeWarning, ibPartitionLookupFailedEvent,
+				"Failed to look up IB partition %s of instance %s: %v",
+				currInstance.HostChannelAdapters[0].IbPartitionId, currInstance.Id, err)
+
 			return nil, fmt.Errorf("failed to get IB network for instance %s: %w", currInstance.Id, err)
 		}
-		if ibPartition != nil {
-			additionalLabels["crusoe.ai/ib.partition.name"] = ibPartition.Name
-			additionalLabels["crusoe.ai/ib.partition.id"] = ibPartition.Id
-			additionalLabels["crusoe.ai/ib.partition.networkId"] = ibPartition.IbNetworkId
+		if partition != nil {
+			ibPartition = partition
 		}
 	}
-	additionalLabels["crusoe.ai/instance.id"] = currInstance.Id
-	additionalLabels["crusoe.ai/instance.group.id"] = currInstance.InstanceGroupId
-	additionalLabels["crusoe.ai/instance.template.id"] = currInstance.InstanceTemplateId
-	additionalLabels["crusoe.ai/instance.state"] = currInstance.State
-	additionalLabels["crusoe.ai/pod.id"] = currInstance.PodId
+	nodeLabels.add(LabelGroupIB, "ib.partition.name", ibPartition.Name)
+	nodeLabels.add(LabelGroupIB, "ib.partition.id", ibPartition.Id)
+	nodeLabels.add(LabelGroupIB, "ib.partition.networkId", ibPartition.IbNetworkId)
+	nodeLabels.add(LabelGroupInstance, "instance.id", currInstance.Id)
+	nodeLabels.add(LabelGroupInstance, LabelInstanceGroupID, currInstance.InstanceGroupId)
+	nodeLabels.add(LabelGroupInstance, LabelInstanceTemplateID, currInstance.InstanceTemplateId)
+	nodeLabels.add(LabelGroupInstance, "instance.state", currInstance.State)
+	nodeLabels.add(LabelGroupPod, "pod.id", currInstance.PodId)
+	nodeLabels.add(LabelGroupCluster, "cluster.id", i.clusterID)
+	if err := i.applyInitialTaints(ctx, node, currInstance); err != nil {
+		return nil, err
+	}
+	annotations := nodeLabels.annotations
+	if !i.config.DisableInstanceAnnotations {
+		details, err := instanceAnnotations(i.config.Labels.Prefix, currInstance)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(annotations, details)
+	}
+	if err := i.reconcileNodeAnnotations(ctx, node, annotations); err != nil {
+		klog.ErrorS(err, "Failed to update instance annotations", "node", klog.KObj(node))
+	}
 	metadata := cloudprovider.InstanceMetadata{
 		ProviderID:       ProviderPrefix + currInstance.Id,
 		InstanceType:     currInstance.Type_,
 		Region:           currInstance.Location,
-		AdditionalLabels: additionalLabels,
+		AdditionalLabels: nodeLabels.labels,
 		NodeAddresses:    nodeAddress,
 	}
-	klog.Infof("InstanceMetadata for (%v:%v)", node.Name, metadata)
+	klog.V(4).InfoS("Got instance metadata", "node", klog.KObj(node), "providerID", metadata.ProviderID,
+		"instanceType", metadata.InstanceType, "region", metadata.Region)
 
 	return &metadata, nil
 }
 
 func NewCrusoeInstances(c client.APIClient) *Instances {
+	return NewCrusoeInstancesWithConfig(c, DefaultConfig())
+}
+
+// NewCrusoeInstancesWithConfig creates Instances using the given cloud config settings.
+func NewCrusoeInstancesWithConfig(c client.APIClient, config Config) *Instances {
+	config.ApplyDefaults()
+	registerMetrics()
+
 	return &Instances{
+		notFound:  newNotFoundTracker(config.InstanceNotFound.GracePeriod.Duration),
 		apiClient: c,
+		config:    config,
 	}
 }
 
+// SetKubeClient sets the client used to write instance details that do not fit
+// in cloudprovider.InstanceMetadata to the Node object.
+func (i *Instances) SetKubeClient(kubeClient clientset.Interface) {
+	i.kubeClient = kubeClient
+}
+
+// SetClusterID sets the cluster ID nodes are labeled with, so that other components
+// can read it from the Node objects.
+func (i *Instances) SetClusterID(clusterID string) {
+	i.clusterID = clusterID
+}
+
 func getProviderID(ctx context.Context, node *v1.Node, i *Instances) (string, error) {
 	providerID := node.Spec.ProviderID
+	mismatch := false
 	// While kubelet does not update the node.spec or the node.status.addresses or metadata fields when
 	// node information changes it is still able to update the nodeInfo field in node.status. Kubelet updates
 	// node.Status.NodeInfo.SystemUUID to /sys/class/dmi/id/product_uuid which is the correct VM ID in Crusoe Cloud
 	if node.Status.NodeInfo.SystemUUID != "" &&
 		getInstanceIDFromProviderID(node.Spec.ProviderID) != node.Status.NodeInfo.SystemUUID {
 
-		klog.Warningf("ProviderID and SystemUUID do not match; providerID: "+
-			"%s; systemUUID: %s. Fetching UUID from Crusoe Cloud directly.",
-			providerID, node.Status.NodeInfo.SystemUUID)
+		klog.InfoS("ProviderID and SystemUUID do not match, fetching the instance ID from Crusoe Cloud",
+			"node", klog.KObj(node), "providerID", providerID, "systemUUID", node.Status.NodeInfo.SystemUUID)
+		mismatch = providerID != ""
 		providerID = ""
 	}
 	if providerID == "" {
 		currInstance, err := i.apiClient.GetInstanceByName(ctx, node.Name)
 		if err != nil {
+			if errors.Is(err, client.ErrAmbiguousInstanceName) {
+				i.eventf(node, v1.EventTypeWarning, ambiguousInstanceNameEvent,
+					"Cannot identify the instance of node %s: %v", node.Name, err)
+			} else {
+				i.eventf(node, v1.EventTypeWarning, instanceLookupFailedEvent,
+					"Failed to look up instance by name %s: %v", node.Name, err)
+			}
+
 			return "", fmt.Errorf("failed to get instance by Name %s: %w", node.Name, err)
 		}
 		providerID = ProviderPrefix + currInstance.Id
+		if mismatch && providerID != node.Spec.ProviderID {
+			i.eventf(node, v1.EventTypeNormal, providerIDCorrectedEvent,
+				"ProviderID %s does not match SystemUUID %s, using instance %s found by name",
+				node.Spec.ProviderID, node.Status.NodeInfo.SystemUUID, currInstance.Id)
+		}
 	}
 
 	return providerID, nil
@@ -261,41 +389,41 @@ func getInstanceIDFromProviderID(providerID string) string {
 	return strings.TrimPrefix(providerID, ProviderPrefix)
 }
 
+// getNodeAddress returns the addresses of the instance's primary network interface.
+// The external IP is left out when the instance has no public IP.
 func getNodeAddress(currInstance *crusoeapi.InstanceV1Alpha5) ([]v1.NodeAddress, error) {
-	var nodeAddress []v1.NodeAddress
-	nodeAddress = append(nodeAddress, v1.NodeAddress{
+	if len(currInstance.NetworkInterfaces) == 0 || len(currInstance.NetworkInterfaces[0].Ips) == 0 {
+		return nil, ErrNoNetworkInterface
+	}
+	ips := currInstance.NetworkInterfaces[0].Ips[0]
+	if ips.PrivateIpv4 == nil || ips.PrivateIpv4.Address == "" {
+		return nil, ErrNoPrivateIP
+	}
+
+	nodeAddress := []v1.NodeAddress{{
 		Type:    v1.NodeInternalIP,
-		Address: currInstance.NetworkInterfaces[0].Ips[0].PrivateIpv4.Address,
-	}, v1.NodeAddress{
-		Type:    v1.NodeExternalIP,
-		Address: currInstance.NetworkInterfaces[0].Ips[0].PublicIpv4.Address,
-	}, v1.NodeAddress{
+		Address: ips.PrivateIpv4.Address,
+	}}
+	if ips.PublicIpv4 != nil && ips.PublicIpv4.Address != "" {
+		nodeAddress = append(nodeAddress, v1.NodeAddress{
+			Type:    v1.NodeExternalIP,
+			Address: ips.PublicIpv4.Address,
+		})
+	}
+	nodeAddress = append(nodeAddress, v1.NodeAddress{
 		Type:    v1.NodeHostName,
 		Address: fmt.Sprintf("%s.%s.compute.internal", currInstance.Name, currInstance.Location),
-	},
-	)
+	})
 
 	return nodeAddress, nil
 }
 
-func (i *Instances) handleInstanceNotFoundErr(providerID string, orignalErr error) (instanceShutdown bool, err error) {
-	attempt, ok := i.nodeShutdown.Load(providerID)
-	if !ok {
-		i.nodeShutdown.Store(providerID, 1)
-
-		return false, orignalErr
-	}
-	intAttempt, ok := attempt.(int)
-	if !ok {
-		// store correct data to avoid conversion issues in next iteration
-		i.nodeShutdown.Store(providerID, 1)
-
-		return false, orignalErr
-	}
-	if intAttempt >= 3 {
+func (i *Instances) handleInstanceNotFoundErr(ctx context.Context, providerID string, node *v1.Node,
+	orignalErr error,
+) (instanceShutdown bool, err error) {
+	if i.notFoundAttempt(ctx, providerID, node) > i.config.InstanceNotFound.ShutdownAttempts {
 		return true, nil
 	}
-	i.nodeShutdown.Store(providerID, (intAttempt + 1))
 
 	return false, orignalErr
 }
@@ -0,0 +1,162 @@
+package instances
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/validate/content"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+const (
+	DefaultLabelPrefix = "crusoe.ai"
+
+	// LabelGroupInstance covers the crusoe.ai/instance.* labels.
+	LabelGroupInstance = "instance"
+	// LabelGroupIB covers the crusoe.ai/ib.partition.* labels.
+	LabelGroupIB = "ib"
+	// LabelGroupPod covers the crusoe.ai/pod.* labels.
+	LabelGroupPod = "pod"
+	// LabelGroupCluster covers the crusoe.ai/cluster.* labels.
+	LabelGroupCluster = "cluster"
+
+	// LabelInstanceGroupID and LabelInstanceTemplateID are the names of the instance group and
+	// instance template labels, under the label prefix.
+	LabelInstanceGroupID    = "instance.group.id"
+	LabelInstanceTemplateID = "instance.template.id"
+
+	// labelValueHashLength is the number of hex characters of the value digest
+	// kept when a value has to be shortened to fit in a label.
+	labelValueHashLength = 10
+)
+
+var (
+	ErrInvalidLabelPrefix = errors.New("invalid label prefix")
+	ErrUnknownLabelGroup  = errors.New("unknown label group")
+
+	invalidLabelValueChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// LabelConfig controls the crusoe.ai labels InstanceMetadata adds to nodes.
+type LabelConfig struct {
+	// Prefix is the domain used for label and annotation keys, "crusoe.ai" by default.
+	Prefix string `json:"prefix,omitempty"`
+	// Groups lists the enabled label groups (instance, ib, pod, cluster). All groups are enabled when empty.
+	Groups []string `json:"groups,omitempty"`
+}
+
+func (c *LabelConfig) applyDefaults() {
+	if c.Prefix == "" {
+		c.Prefix = DefaultLabelPrefix
+	}
+	if len(c.Groups) == 0 {
+		c.Groups = []string{LabelGroupInstance, LabelGroupIB, LabelGroupPod, LabelGroupCluster}
+	}
+}
+
+func (c *LabelConfig) validate() error {
+	if errs := validation.IsDNS1123Subdomain(c.Prefix); len(errs) > 0 {
+		return fmt.Errorf("%w %q: %s", ErrInvalidLabelPrefix, c.Prefix, strings.Join(errs, "; "))
+	}
+	for _, group := range c.Groups {
+		switch group {
+		case LabelGroupInstance, LabelGroupIB, LabelGroupPod, LabelGroupCluster:
+		default:
+			return fmt.Errorf("%w %q", ErrUnknownLabelGroup, group)
+		}
+	}
+
+	return nil
+}
+
+// Key returns the key of the label name in the group, and whether the group is enabled.
+// The defaults are applied to unset fields.
+func (c *LabelConfig) Key(group, name string) (string, bool) {
+	config := *c
+	config.applyDefaults()
+
+	return config.Prefix + "/" + name, slices.Contains(config.Groups, group)
+}
+
+// nodeLabels collects the labels for a node. Values that cannot be used as a label
+// as-is are sanitized or hashed and the original value is kept in an annotation
+// with the same key, which is removed again once the value is valid.
+type nodeLabels struct {
+	config      LabelConfig
+	labels      map[string]string
+	annotations map[string]string
+}
+
+func newNodeLabels(config LabelConfig) *nodeLabels {
+	return &nodeLabels{
+		config:      config,
+		labels:      make(map[string]string),
+		annotations: make(map[string]string),
+	}
+}
+
+// add sets the label <prefix>/<name> if its group is enabled and value is not empty. Otherwise
+// the original value annotated when the label was last sanitized is removed.
+func (n *nodeLabels) add(group, name, value string) {
+	key, enabled := n.config.Key(group, name)
+	if errs := content.IsLabelKey(key); len(errs) > 0 {
+		klog.InfoS("Skipping invalid label key", "key", key, "reason", strings.Join(errs, "; "))
+
+		return
+	}
+	if value == "" || !enabled {
+		// the label is not written, so an original annotated for an earlier value is stale
+		n.annotations[key] = ""
+
+		return
+	}
+	labelValue := sanitizeLabelValue(value)
+	n.labels[key] = labelValue
+	if labelValue == value {
+		// an empty value removes the original annotated when the value was last sanitized
+		n.annotations[key] = ""
+
+		return
+	}
+	klog.V(2).InfoS("Label value is not valid, annotating the original", "key", key,
+		"value", value, "labelValue", labelValue)
+	n.annotations[key] = value
+}
+
+// sanitizeLabelValue returns value if it is a valid label value. Otherwise invalid
+// characters are replaced, and values that are still too long are truncated and
+// suffixed with a digest of the original so that distinct values stay distinct.
+func sanitizeLabelValue(value string) string {
+	if len(content.IsLabelValue(value)) == 0 {
+		return value
+	}
+	sanitized := invalidLabelValueChars.ReplaceAllString(value, "-")
+	sanitized = trimToAlphanumeric(sanitized)
+	if len(sanitized) <= content.LabelValueMaxLength && len(content.IsLabelValue(sanitized)) == 0 {
+		return sanitized
+	}
+
+	sum := sha256.Sum256([]byte(value))
+	digest := hex.EncodeToString(sum[:])[:labelValueHashLength]
+	maxPrefix := content.LabelValueMaxLength - labelValueHashLength - 1
+	if len(sanitized) > maxPrefix {
+		sanitized = trimToAlphanumeric(sanitized[:maxPrefix])
+	}
+	if sanitized == "" {
+		return digest
+	}
+
+	return sanitized + "-" + digest
+}
+
+func trimToAlphanumeric(value string) string {
+	return strings.TrimFunc(value, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9')
+	})
+}
@@ -0,0 +1,191 @@
+package instances_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1alpha5 "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/validate/content"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testInstance() *v1alpha5.InstanceV1Alpha5 {
+	return &v1alpha5.InstanceV1Alpha5{
+		Id:    TESTInstanceID,
+		State: "STATE_RUNNING",
+		NetworkInterfaces: []v1alpha5.NetworkInterface{
+			{
+				Ips: []v1alpha5.IpAddresses{
+					{
+						PrivateIpv4: &v1alpha5.PrivateIpv4Address{Address: "10.0.0.1"},
+						PublicIpv4:  &v1alpha5.PublicIpv4Address{Address: "192.168.0.1"},
+					},
+				},
+			},
+		},
+		Name:     TESTNodeName,
+		Location: TestLocation,
+	}
+}
+
+func testNode() *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: TESTNodeName,
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: ProviderIDPrefix + TESTInstanceID,
+		},
+	}
+}
+
+func TestInstanceMetadataSkipsEmptyLabels(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstances(mockClient)
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(testInstance(), nil, nil)
+
+	metadata, err := instanceService.InstanceMetadata(context.Background(), testNode())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"crusoe.ai/instance.id":    TESTInstanceID,
+		"crusoe.ai/instance.state": "STATE_RUNNING",
+	}, metadata.AdditionalLabels)
+}
+
+func TestInstanceMetadataClusterLabel(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstances(mockClient)
+	instanceService.SetClusterID("cluster-a")
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(testInstance(), nil, nil)
+
+	metadata, err := instanceService.InstanceMetadata(context.Background(), testNode())
+	require.NoError(t, err)
+	require.Equal(t, "cluster-a", metadata.AdditionalLabels["crusoe.ai/cluster.id"])
+}
+
+func TestInstanceMetadataLabelConfig(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstancesWithConfig(mockClient, instances.Config{
+		Labels: instances.LabelConfig{
+			Prefix: "example.com",
+			Groups: []string{instances.LabelGroupPod},
+		},
+	})
+	instance := testInstance()
+	instance.PodId = "pod-1"
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(instance, nil, nil)
+
+	metadata, err := instanceService.InstanceMetadata(context.Background(), testNode())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"example.com/pod.id": "pod-1"}, metadata.AdditionalLabels)
+}
+
+func TestInstanceMetadataInvalidLabelValues(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	node := testNode()
+	kubeClient := fake.NewClientset(node)
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstances(mockClient)
+	instanceService.SetKubeClient(kubeClient)
+
+	longValue := strings.Repeat("a", 100)
+	instance := testInstance()
+	instance.InstanceGroupId = "group with spaces"
+	instance.InstanceTemplateId = longValue
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(instance, nil, nil)
+
+	metadata, err := instanceService.InstanceMetadata(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, "group-with-spaces", metadata.AdditionalLabels["crusoe.ai/instance.group.id"])
+	templateLabel := metadata.AdditionalLabels["crusoe.ai/instance.template.id"]
+	require.Empty(t, content.IsLabelValue(templateLabel))
+	require.NotEqual(t, longValue, templateLabel)
+
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.Background(), TESTNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "group with spaces", updated.Annotations["crusoe.ai/instance.group.id"])
+	require.Equal(t, longValue, updated.Annotations["crusoe.ai/instance.template.id"])
+	require.NotContains(t, updated.Annotations, "crusoe.ai/instance.id")
+}
+
+func TestInstanceMetadataRemovesOriginalLabelValue(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the group ID was sanitized when the node was last synced
+	node := testNode()
+	node.Annotations = map[string]string{"crusoe.ai/instance.group.id": "group with spaces"}
+	kubeClient := fake.NewClientset(node)
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstances(mockClient)
+	instanceService.SetKubeClient(kubeClient)
+
+	instance := testInstance()
+	instance.InstanceGroupId = "group-1"
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(instance, nil, nil)
+
+	metadata, err := instanceService.InstanceMetadata(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, "group-1", metadata.AdditionalLabels["crusoe.ai/instance.group.id"])
+
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.Background(), TESTNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, updated.Annotations, "crusoe.ai/instance.group.id")
+}
+
+func TestInstanceMetadataRemovesOriginalOfUnwrittenLabels(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the group ID and pod ID were sanitized when the node was last synced
+	node := testNode()
+	node.Annotations = map[string]string{
+		"crusoe.ai/instance.group.id": "group with spaces",
+		"crusoe.ai/pod.id":            "pod with spaces",
+	}
+	kubeClient := fake.NewClientset(node)
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstancesWithConfig(mockClient, instances.Config{
+		Labels: instances.LabelConfig{Groups: []string{instances.LabelGroupInstance}},
+	})
+	instanceService.SetKubeClient(kubeClient)
+
+	// the instance left its group, and the pod labels were disabled
+	instance := testInstance()
+	instance.PodId = "pod-1"
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(instance, nil, nil)
+
+	metadata, err := instanceService.InstanceMetadata(context.Background(), node)
+	require.NoError(t, err)
+	require.NotContains(t, metadata.AdditionalLabels, "crusoe.ai/instance.group.id")
+	require.NotContains(t, metadata.AdditionalLabels, "crusoe.ai/pod.id")
+
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.Background(), TESTNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, updated.Annotations, "crusoe.ai/instance.group.id")
+	require.NotContains(t, updated.Annotations, "crusoe.ai/pod.id")
+}
@@ -0,0 +1,32 @@
+package instances
+
+import (
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+)
+
+// loggedInstance logs the identifying fields of an instance. Addresses, SSH keys,
+// disks and the rest of the instance are left out of the logs.
+type loggedInstance struct {
+	instance *crusoeapi.InstanceV1Alpha5
+}
+
+// MarshalLog implements logr.Marshaler, which klog uses for structured values.
+func (l loggedInstance) MarshalLog() any {
+	if l.instance == nil {
+		return nil
+	}
+
+	return struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		State    string `json:"state"`
+		Location string `json:"location"`
+	}{
+		ID:       l.instance.Id,
+		Name:     l.instance.Name,
+		Type:     l.instance.Type_,
+		State:    l.instance.State,
+		Location: l.instance.Location,
+	}
+}
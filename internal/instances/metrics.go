@@ -0,0 +1,39 @@
+package instances
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	metricsNamespace = "crusoe"
+	metricsSubsystem = "instances"
+
+	trackedStateGracePeriod      = "grace_period"
+	trackedStateShutdownAttempts = "shutdown_attempts"
+)
+
+//nolint:gochecknoglobals // metrics are registered once on the global registry
+var (
+	trackedNotFoundInstances = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "not_found_tracked",
+			Help:           "Number of instances missing from the Crusoe API that are tracked, by what they are tracked for.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"state"},
+	)
+
+	metricRegistration sync.Once
+)
+
+// registerMetrics registers the metrics that are to be monitored.
+func registerMetrics() {
+	metricRegistration.Do(func() {
+		legacyregistry.MustRegister(trackedNotFoundInstances)
+	})
+}
@@ -0,0 +1,165 @@
+package instances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	DefaultShutdownAttempts = 3
+
+	// AnnotationInstanceNotFoundSince records when the instance was first missing from the Crusoe API.
+	AnnotationInstanceNotFoundSince = "instance.notFoundSince"
+	// AnnotationInstanceNotFoundAttempts counts the shutdown checks that could not find the instance.
+	AnnotationInstanceNotFoundAttempts = "instance.notFoundAttempts"
+)
+
+var ErrInvalidNotFoundConfig = errors.New("invalid instance not found config")
+
+// NotFoundConfig controls how long and how often an instance has to be missing from
+// the Crusoe API before its node is reported as deleted or shut down.
+type NotFoundConfig struct {
+	// GracePeriod is how long an instance can be missing before InstanceExists returns false.
+	GracePeriod metav1.Duration `json:"gracePeriod,omitempty"`
+	// ShutdownAttempts is the number of failed lookups after which InstanceShutdown returns true.
+	ShutdownAttempts int `json:"shutdownAttempts,omitempty"`
+}
+
+func (c *NotFoundConfig) applyDefaults() {
+	if c.GracePeriod.Duration == 0 {
+		c.GracePeriod.Duration = InstanceNotFoundInterval
+	}
+	if c.ShutdownAttempts == 0 {
+		c.ShutdownAttempts = DefaultShutdownAttempts
+	}
+}
+
+func (c *NotFoundConfig) validate() error {
+	if c.GracePeriod.Duration < 0 {
+		return fmt.Errorf("%w: gracePeriod must not be negative", ErrInvalidNotFoundConfig)
+	}
+	if c.ShutdownAttempts < 0 {
+		return fmt.Errorf("%w: shutdownAttempts must not be negative", ErrInvalidNotFoundConfig)
+	}
+
+	return nil
+}
+
+// notFoundSince returns when the instance was first missing, recording now if it was not
+// missing before. The time is kept in memory and, when the node is known, in a node
+// annotation so that a restarted or newly elected CCM continues from the same point.
+func (i *Instances) notFoundSince(ctx context.Context, providerID string, node *v1.Node, now time.Time) time.Time {
+	earliest := now
+	if persisted, ok := i.nodeAnnotation(node, AnnotationInstanceNotFoundSince); ok {
+		persistedTime, err := time.Parse(time.RFC3339, persisted)
+		if err != nil {
+			klog.ErrorS(err, "Ignoring invalid annotation", "node", klog.KObj(node),
+				"annotation", AnnotationInstanceNotFoundSince)
+		} else if persistedTime.Before(earliest) {
+			earliest = persistedTime
+		}
+	}
+	since := i.notFound.since(providerID, earliest)
+	i.persistNotFoundState(ctx, node, map[string]string{
+		AnnotationInstanceNotFoundSince: since.UTC().Format(time.RFC3339),
+	})
+
+	return since
+}
+
+// notFoundAttempt records another failed lookup of the instance and returns the number
+// of failed lookups so far.
+func (i *Instances) notFoundAttempt(ctx context.Context, providerID string, node *v1.Node) int {
+	floor := 0
+	if persisted, ok := i.nodeAnnotation(node, AnnotationInstanceNotFoundAttempts); ok {
+		persistedAttempts, err := strconv.Atoi(persisted)
+		if err != nil {
+			klog.ErrorS(err, "Ignoring invalid annotation", "node", klog.KObj(node),
+				"annotation", AnnotationInstanceNotFoundAttempts)
+		} else {
+			floor = persistedAttempts
+		}
+	}
+	// counting stops once the threshold is exceeded to avoid patching the node on every check
+	attempts, changed := i.notFound.attempt(providerID, floor, i.config.InstanceNotFound.ShutdownAttempts)
+	if changed {
+		i.persistNotFoundState(ctx, node, map[string]string{
+			AnnotationInstanceNotFoundAttempts: strconv.Itoa(attempts),
+		})
+	}
+
+	return attempts
+}
+
+// instanceFound resets the not found state of an instance that was found again.
+func (i *Instances) instanceFound(ctx context.Context, providerID string, node *v1.Node) {
+	i.notFound.forget(providerID)
+	i.persistNotFoundState(ctx, node, map[string]string{
+		AnnotationInstanceNotFoundSince:    "",
+		AnnotationInstanceNotFoundAttempts: "",
+	})
+}
+
+// SetInformers registers NodeDeleted on the node informer shared by the controllers, so that
+// the not found state of deleted nodes is dropped whichever controllers are enabled.
+func (i *Instances) SetInformers(informerFactory informers.SharedInformerFactory) {
+	_, err := informerFactory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: i.NodeDeleted,
+	})
+	if err != nil {
+		klog.ErrorS(err, "Failed to watch node deletions")
+	}
+}
+
+// NodeDeleted drops the in-memory not found state of a deleted node. It is registered
+// as the delete handler of the shared node informer by SetInformers.
+func (i *Instances) NodeDeleted(obj any) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		node, ok = tombstone.Obj.(*v1.Node)
+		if !ok {
+			return
+		}
+	}
+	if node.Spec.ProviderID != "" {
+		i.notFound.forget(node.Spec.ProviderID)
+	}
+	if node.Status.NodeInfo.SystemUUID != "" {
+		i.notFound.forget(ProviderPrefix + node.Status.NodeInfo.SystemUUID)
+	}
+}
+
+func (i *Instances) nodeAnnotation(node *v1.Node, name string) (string, bool) {
+	if node == nil {
+		return "", false
+	}
+	value, ok := node.Annotations[i.config.Labels.Prefix+"/"+name]
+
+	return value, ok
+}
+
+func (i *Instances) persistNotFoundState(ctx context.Context, node *v1.Node, values map[string]string) {
+	if node == nil {
+		return
+	}
+	annotations := make(map[string]string, len(values))
+	for name, value := range values {
+		annotations[i.config.Labels.Prefix+"/"+name] = value
+	}
+	if err := i.reconcileNodeAnnotations(ctx, node, annotations); err != nil {
+		klog.ErrorS(err, "Failed to persist instance not found state", "node", klog.KObj(node))
+	}
+}
@@ -0,0 +1,170 @@
+package instances_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestInstanceExistsNotFoundGracePeriodPersisted(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	node := testNode()
+	kubeClient := fake.NewClientset(node)
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(nil,
+		nil, client.ErrInstanceNotFound).AnyTimes()
+	config := instances.Config{
+		InstanceNotFound: instances.NotFoundConfig{GracePeriod: metav1.Duration{Duration: time.Hour}},
+	}
+	instanceService := instances.NewCrusoeInstancesWithConfig(mockClient, config)
+	instanceService.SetKubeClient(kubeClient)
+
+	exists, err := instanceService.InstanceExists(context.Background(), node)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.Background(), TESTNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	since, err := time.Parse(time.RFC3339, updated.Annotations["crusoe.ai/instance.notFoundSince"])
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), since, time.Minute)
+
+	// a new leader continues from the persisted time instead of starting a new grace period
+	updated.Annotations["crusoe.ai/instance.notFoundSince"] = time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+	newLeader := instances.NewCrusoeInstancesWithConfig(mockClient, config)
+	newLeader.SetKubeClient(kubeClient)
+	exists, err = newLeader.InstanceExists(context.Background(), updated)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestInstanceShutdownNotFoundAttemptsPersisted(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	node := testNode()
+	kubeClient := fake.NewClientset(node)
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(nil,
+		nil, client.ErrInstanceNotFound).AnyTimes()
+	config := instances.Config{
+		InstanceNotFound: instances.NotFoundConfig{ShutdownAttempts: 1},
+	}
+	instanceService := instances.NewCrusoeInstancesWithConfig(mockClient, config)
+	instanceService.SetKubeClient(kubeClient)
+
+	shutdown, err := instanceService.InstanceShutdown(context.Background(), node)
+	require.ErrorIs(t, err, client.ErrInstanceNotFound)
+	require.False(t, shutdown)
+
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.Background(), TESTNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "1", updated.Annotations["crusoe.ai/instance.notFoundAttempts"])
+
+	newLeader := instances.NewCrusoeInstancesWithConfig(mockClient, config)
+	newLeader.SetKubeClient(kubeClient)
+	shutdown, err = newLeader.InstanceShutdown(context.Background(), updated)
+	require.NoError(t, err)
+	require.True(t, shutdown)
+}
+
+func TestInstanceShutdownNotFoundAttemptsReset(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstancesWithConfig(mockClient, instances.Config{
+		InstanceNotFound: instances.NotFoundConfig{ShutdownAttempts: 1},
+	})
+	providerID := ProviderIDPrefix + TESTInstanceID
+	notFound := mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(nil,
+		nil, client.ErrInstanceNotFound)
+	found := mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(testInstance(),
+		nil, nil).After(notFound)
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(nil,
+		nil, client.ErrInstanceNotFound).After(found)
+
+	_, err := instanceService.InstanceShutdownByProviderID(context.Background(), providerID)
+	require.ErrorIs(t, err, client.ErrInstanceNotFound)
+
+	shutdown, err := instanceService.InstanceShutdownByProviderID(context.Background(), providerID)
+	require.NoError(t, err)
+	require.False(t, shutdown)
+
+	// the instance showed up again, so counting starts over
+	shutdown, err = instanceService.InstanceShutdownByProviderID(context.Background(), providerID)
+	require.ErrorIs(t, err, client.ErrInstanceNotFound)
+	require.False(t, shutdown)
+}
+
+func TestNodeDeletedForgetsNotFoundState(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(nil,
+		nil, client.ErrInstanceNotFound).Times(2)
+	instanceService := instances.NewCrusoeInstancesWithConfig(mockClient, instances.Config{
+		InstanceNotFound: instances.NotFoundConfig{ShutdownAttempts: 1},
+	})
+	providerID := ProviderIDPrefix + TESTInstanceID
+
+	_, err := instanceService.InstanceShutdownByProviderID(context.Background(), providerID)
+	require.ErrorIs(t, err, client.ErrInstanceNotFound)
+
+	instanceService.NodeDeleted(testNode())
+
+	// a node recycling the provider ID does not inherit the attempts of the deleted node
+	shutdown, err := instanceService.InstanceShutdownByProviderID(context.Background(), providerID)
+	require.ErrorIs(t, err, client.ErrInstanceNotFound)
+	require.False(t, shutdown)
+}
+
+func TestSetInformersForgetsDeletedNodes(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	kubeClient := fake.NewClientset(testNode())
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(nil,
+		nil, client.ErrInstanceNotFound).AnyTimes()
+	instanceService := instances.NewCrusoeInstancesWithConfig(mockClient, instances.Config{
+		InstanceNotFound: instances.NotFoundConfig{ShutdownAttempts: 1},
+	})
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	instanceService.SetInformers(informerFactory)
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+	providerID := ProviderIDPrefix + TESTInstanceID
+
+	_, err := instanceService.InstanceShutdownByProviderID(ctx, providerID)
+	require.ErrorIs(t, err, client.ErrInstanceNotFound)
+
+	require.NoError(t, kubeClient.CoreV1().Nodes().Delete(ctx, testNode().Name, metav1.DeleteOptions{}))
+
+	// counting starts over once the informer reports the deletion
+	require.Eventually(t, func() bool {
+		_, err := instanceService.InstanceShutdownByProviderID(ctx, providerID)
+
+		return errors.Is(err, client.ErrInstanceNotFound)
+	}, 10*time.Second, 50*time.Millisecond)
+}
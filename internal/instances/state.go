@@ -0,0 +1,104 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+)
+
+// State is the state of a Crusoe v1alpha5 instance as returned by the API.
+//
+// Only the states below are known: STATE_RUNNING is the instance state documented in the
+// v1alpha5 API reference, STATE_SHUTOFF and STATE_SHUTDOWN are the states the CCM has
+// always treated as shut down. Deleted instances are not reported with a state, the API
+// no longer finds them.
+type State string
+
+const (
+	StateRunning  State = "STATE_RUNNING"
+	StateShutdown State = "STATE_SHUTDOWN"
+	StateShutoff  State = "STATE_SHUTOFF"
+)
+
+// Lifecycle is what an instance state means for the node backed by the instance.
+type Lifecycle int
+
+const (
+	// LifecycleRunning instances exist and are not shut down.
+	LifecycleRunning Lifecycle = iota
+	// LifecycleTransient instances are in any other state, e.g. starting or rebooting.
+	// Their nodes are neither tainted as shut down nor deleted while the state lasts.
+	LifecycleTransient
+	// LifecycleShutdown instances exist but are not running.
+	LifecycleShutdown
+)
+
+func (l Lifecycle) String() string {
+	switch l {
+	case LifecycleRunning:
+		return "Running"
+	case LifecycleTransient:
+		return "Transient"
+	case LifecycleShutdown:
+		return "Shutdown"
+	}
+
+	return "Unknown"
+}
+
+// Shutdown reports whether the node should be treated as shut down.
+func (l Lifecycle) Shutdown() bool { return l == LifecycleShutdown }
+
+// Transient reports whether the instance is in a state that is neither running nor shut down.
+func (l Lifecycle) Transient() bool { return l == LifecycleTransient }
+
+// Lifecycle maps the state to its lifecycle. States that are not known are treated as
+// transient, so they never delete or taint a node.
+func (s State) Lifecycle() Lifecycle {
+	switch s {
+	case StateRunning:
+		return LifecycleRunning
+	case StateShutdown, StateShutoff:
+		return LifecycleShutdown
+	}
+
+	return LifecycleTransient
+}
+
+// Known reports whether the state is one of the states above.
+func (s State) Known() bool {
+	switch s {
+	case StateRunning, StateShutdown, StateShutoff:
+		return true
+	}
+
+	return false
+}
+
+// InstanceState is the raw state of an instance and whether the instance is running.
+type InstanceState struct {
+	State   string
+	Running bool
+}
+
+// InstanceStates lists the instances of the project and returns their states by provider ID,
+// so that the state of every node is known from a single listing.
+func (i *Instances) InstanceStates(ctx context.Context) (_ map[string]InstanceState, err error) {
+	ctx, span := startSpan(ctx, "InstanceStates")
+	defer func() { endSpan(span, err) }()
+
+	instances, err := i.apiClient.ListInstances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	states := make(map[string]InstanceState, len(instances))
+	for index := range instances {
+		state := State(instances[index].State)
+		states[ProviderPrefix+instances[index].Id] = InstanceState{
+			State:   string(state),
+			Running: state.Lifecycle() == LifecycleRunning,
+		}
+	}
+
+	return states, nil
+}
@@ -0,0 +1,75 @@
+package instances_test
+
+import (
+	"context"
+	"testing"
+
+	v1alpha5 "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceStates(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		state     instances.State
+		lifecycle instances.Lifecycle
+		shutdown  bool
+	}{
+		{state: instances.StateRunning, lifecycle: instances.LifecycleRunning},
+		{state: instances.StateShutdown, lifecycle: instances.LifecycleShutdown, shutdown: true},
+		{state: instances.StateShutoff, lifecycle: instances.LifecycleShutdown, shutdown: true},
+		// states that are not documented never delete or taint a node
+		{state: "STATE_DELETED", lifecycle: instances.LifecycleTransient},
+		{state: "STATE_SOMETHING_NEW", lifecycle: instances.LifecycleTransient},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.state), func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			lifecycle := tt.state.Lifecycle()
+			require.Equal(t, tt.lifecycle, lifecycle)
+			require.Equal(t, tt.shutdown, lifecycle.Shutdown())
+			require.Equal(t, tt.lifecycle == instances.LifecycleTransient, lifecycle.Transient())
+
+			mockClient := mock_client.NewMockApiClient(ctrl)
+			instanceService := instances.NewCrusoeInstances(mockClient)
+			instance := testInstance()
+			instance.State = string(tt.state)
+			mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(instance, nil, nil).Times(2)
+
+			exists, err := instanceService.InstanceExistsByProviderID(context.Background(), ProviderIDPrefix+TESTInstanceID)
+			require.NoError(t, err)
+			require.True(t, exists)
+
+			shutdown, err := instanceService.InstanceShutdownByProviderID(context.Background(), ProviderIDPrefix+TESTInstanceID)
+			require.NoError(t, err)
+			require.Equal(t, tt.shutdown, shutdown)
+		})
+	}
+}
+
+func TestInstanceStatesListing(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstances(mockClient)
+	mockClient.EXPECT().ListInstances(gomock.Any()).Return([]v1alpha5.InstanceV1Alpha5{
+		{Id: "running", State: "STATE_RUNNING"},
+		{Id: "shutoff", State: "STATE_SHUTOFF"},
+	}, nil)
+
+	states, err := instanceService.InstanceStates(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]instances.InstanceState{
+		ProviderIDPrefix + "running": {State: "STATE_RUNNING", Running: true},
+		ProviderIDPrefix + "shutoff": {State: "STATE_SHUTOFF"},
+	}, states)
+}
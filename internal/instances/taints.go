@@ -0,0 +1,169 @@
+package instances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/validate/content"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+	cloudnodeutil "k8s.io/cloud-provider/node/helpers"
+	"k8s.io/klog/v2"
+)
+
+var ErrInvalidTaintRule = errors.New("invalid taint rule")
+
+// TaintRule adds Taint to a node on initialization when its instance matches every
+// condition set in the rule. Conditions that are not set match any instance.
+type TaintRule struct {
+	// InstanceType is a glob matched against the instance type, e.g. "*-ib.8x".
+	InstanceType string `json:"instanceType,omitempty"`
+	// HasGPU matches instances whose type does (true) or does not (false) have GPUs.
+	HasGPU *bool `json:"hasGPU,omitempty"`
+	// IBPartitionID matches instances with a host channel adapter in the IB partition.
+	IBPartitionID string `json:"ibPartitionId,omitempty"`
+	// InstanceGroupID matches instances in the instance group.
+	InstanceGroupID string `json:"instanceGroupId,omitempty"`
+	// InstanceTemplateID matches instances created from the instance template.
+	InstanceTemplateID string `json:"instanceTemplateId,omitempty"`
+	// ProjectID matches instances in the project.
+	ProjectID string `json:"projectId,omitempty"`
+
+	Taint v1.Taint `json:"taint"`
+}
+
+func (r *TaintRule) validate() error {
+	if errs := content.IsLabelKey(r.Taint.Key); len(errs) > 0 {
+		return fmt.Errorf("%w: taint key %q: %s", ErrInvalidTaintRule, r.Taint.Key, strings.Join(errs, "; "))
+	}
+	if r.Taint.Value != "" {
+		if errs := content.IsLabelValue(r.Taint.Value); len(errs) > 0 {
+			return fmt.Errorf("%w: taint value %q: %s", ErrInvalidTaintRule, r.Taint.Value, strings.Join(errs, "; "))
+		}
+	}
+	switch r.Taint.Effect {
+	case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+	default:
+		return fmt.Errorf("%w: unsupported taint effect %q", ErrInvalidTaintRule, r.Taint.Effect)
+	}
+	if r.InstanceType != "" {
+		if _, err := path.Match(r.InstanceType, ""); err != nil {
+			return fmt.Errorf("%w: instance type pattern %q: %w", ErrInvalidTaintRule, r.InstanceType, err)
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the instance satisfies the rule. hasGPU is only called
+// when the rule has a GPU condition, since it needs a Crusoe API call.
+func (r *TaintRule) matches(instance *crusoeapi.InstanceV1Alpha5, hasGPU func() (bool, error)) (bool, error) {
+	if r.InstanceType != "" {
+		// the pattern is validated when the config is loaded
+		if matched, _ := path.Match(r.InstanceType, instance.Type_); !matched {
+			return false, nil
+		}
+	}
+	if r.InstanceGroupID != "" && r.InstanceGroupID != instance.InstanceGroupId {
+		return false, nil
+	}
+	if r.InstanceTemplateID != "" && r.InstanceTemplateID != instance.InstanceTemplateId {
+		return false, nil
+	}
+	if r.ProjectID != "" && r.ProjectID != instance.ProjectId {
+		return false, nil
+	}
+	if r.IBPartitionID != "" && !inIBPartition(instance, r.IBPartitionID) {
+		return false, nil
+	}
+	if r.HasGPU != nil {
+		gpu, err := hasGPU()
+		if err != nil {
+			return false, err
+		}
+		if gpu != *r.HasGPU {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func inIBPartition(instance *crusoeapi.InstanceV1Alpha5, ibPartitionID string) bool {
+	for _, hca := range instance.HostChannelAdapters {
+		if hca.IbPartitionId == ibPartitionID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// taintsForInstance returns the taints of every configured rule the instance matches. A failed
+// VM type lookup is returned rather than skipping the GPU rules, since the taints are only
+// added before the node is initialized and the cloud node controller retries on errors.
+func (i *Instances) taintsForInstance(ctx context.Context, instance *crusoeapi.InstanceV1Alpha5) ([]*v1.Taint, error) {
+	var gpu *bool
+	hasGPU := func() (bool, error) {
+		if gpu == nil {
+			vmType, err := i.apiClient.GetVMType(ctx, instance.ProjectId, instance.Type_)
+			if err != nil {
+				return false, fmt.Errorf("failed to get vm type %s: %w", instance.Type_, err)
+			}
+			found := vmType.NumGpu > 0
+			gpu = &found
+		}
+
+		return *gpu, nil
+	}
+
+	var taints []*v1.Taint
+	for index := range i.config.TaintRules {
+		rule := &i.config.TaintRules[index]
+		matched, err := rule.matches(instance, hasGPU)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			taints = append(taints, &rule.Taint)
+		}
+	}
+
+	return taints, nil
+}
+
+// applyInitialTaints adds the taints from matching rules to a node that has not been
+// initialized by the cloud node controller yet. Nodes that are already initialized are
+// left alone so that taints removed by operators are not added back.
+func (i *Instances) applyInitialTaints(ctx context.Context, node *v1.Node, instance *crusoeapi.InstanceV1Alpha5) error {
+	if i.kubeClient == nil || len(i.config.TaintRules) == 0 || !isUninitialized(node) {
+		return nil
+	}
+	taints, err := i.taintsForInstance(ctx, instance)
+	if err != nil {
+		return err
+	}
+	if len(taints) == 0 {
+		return nil
+	}
+	klog.InfoS("Adding configured taints", "node", klog.KObj(node), "taints", len(taints))
+	if err := cloudnodeutil.AddOrUpdateTaintOnNode(i.kubeClient, node.Name, taints...); err != nil {
+		return fmt.Errorf("failed to add configured taints to node %s: %w", node.Name, err)
+	}
+
+	return nil
+}
+
+func isUninitialized(node *v1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == cloudproviderapi.TaintExternalCloudProvider {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,169 @@
+package instances_test
+
+import (
+	"context"
+	"testing"
+
+	v1alpha5 "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+)
+
+func TestInstanceMetadataTaintRules(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hasGPU := true
+	gpuTaint := v1.Taint{Key: "nvidia.com/gpu", Value: "present", Effect: v1.TaintEffectNoSchedule}
+	groupTaint := v1.Taint{Key: "example.com/group", Effect: v1.TaintEffectNoSchedule}
+	cpuTaint := v1.Taint{Key: "example.com/cpu-only", Effect: v1.TaintEffectPreferNoSchedule}
+	config := instances.Config{
+		TaintRules: []instances.TaintRule{
+			{InstanceType: "*-ib.8x", Taint: gpuTaint},
+			{InstanceGroupID: "other-group", Taint: groupTaint},
+			{HasGPU: &hasGPU, ProjectID: TestProjectID, Taint: cpuTaint},
+		},
+	}
+	config.ApplyDefaults()
+	require.NoError(t, config.Validate())
+
+	node := testNode()
+	node.Spec.Taints = []v1.Taint{{
+		Key:    cloudproviderapi.TaintExternalCloudProvider,
+		Value:  "true",
+		Effect: v1.TaintEffectNoSchedule,
+	}}
+	kubeClient := fake.NewClientset(node)
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstancesWithConfig(mockClient, config)
+	instanceService.SetKubeClient(kubeClient)
+
+	instance := testInstance()
+	instance.Type_ = "h100-80gb-sxm-ib.8x"
+	instance.ProjectId = TestProjectID
+	instance.InstanceGroupId = "group-1"
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(instance, nil, nil)
+	mockClient.EXPECT().GetVMType(gomock.Any(), TestProjectID, instance.Type_).Return(&v1alpha5.ModelType{
+		ProductName: instance.Type_,
+		NumGpu:      8,
+	}, nil)
+
+	_, err := instanceService.InstanceMetadata(context.Background(), node)
+	require.NoError(t, err)
+
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.Background(), TESTNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	keys := make([]string, 0, len(updated.Spec.Taints))
+	for _, taint := range updated.Spec.Taints {
+		keys = append(keys, taint.Key)
+	}
+	require.ElementsMatch(t, []string{
+		cloudproviderapi.TaintExternalCloudProvider,
+		gpuTaint.Key,
+		cpuTaint.Key,
+	}, keys)
+}
+
+func TestInstanceMetadataTaintRulesVMTypeLookupFailure(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hasGPU := true
+	config := instances.Config{
+		TaintRules: []instances.TaintRule{
+			{InstanceType: "*-ib.8x", Taint: v1.Taint{Key: "example.com/ib", Effect: v1.TaintEffectNoSchedule}},
+			{HasGPU: &hasGPU, Taint: v1.Taint{Key: "example.com/gpu", Effect: v1.TaintEffectNoSchedule}},
+		},
+	}
+	config.ApplyDefaults()
+	require.NoError(t, config.Validate())
+
+	node := testNode()
+	node.Spec.Taints = []v1.Taint{{
+		Key:    cloudproviderapi.TaintExternalCloudProvider,
+		Value:  "true",
+		Effect: v1.TaintEffectNoSchedule,
+	}}
+	kubeClient := fake.NewClientset(node)
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstancesWithConfig(mockClient, config)
+	instanceService.SetKubeClient(kubeClient)
+
+	instance := testInstance()
+	instance.Type_ = "h100-80gb-sxm-ib.8x"
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(instance, nil, nil)
+	mockClient.EXPECT().GetVMType(gomock.Any(), gomock.Any(), instance.Type_).Return(nil, context.DeadlineExceeded)
+
+	// the error keeps the node uninitialized so that the cloud node controller retries
+	_, err := instanceService.InstanceMetadata(context.Background(), node)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.Background(), TESTNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, node.Spec.Taints, updated.Spec.Taints)
+}
+
+func TestInstanceMetadataTaintRulesSkipInitializedNodes(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	node := testNode()
+	kubeClient := fake.NewClientset(node)
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	instanceService := instances.NewCrusoeInstancesWithConfig(mockClient, instances.Config{
+		TaintRules: []instances.TaintRule{
+			{InstanceType: "*", Taint: v1.Taint{Key: "example.com/any", Effect: v1.TaintEffectNoSchedule}},
+		},
+	})
+	instanceService.SetKubeClient(kubeClient)
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(testInstance(), nil, nil)
+
+	_, err := instanceService.InstanceMetadata(context.Background(), node)
+	require.NoError(t, err)
+
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.Background(), TESTNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, updated.Spec.Taints)
+}
+
+func TestTaintRuleValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		rule instances.TaintRule
+	}{
+		{
+			name: "missing key",
+			rule: instances.TaintRule{Taint: v1.Taint{Effect: v1.TaintEffectNoSchedule}},
+		},
+		{
+			name: "invalid effect",
+			rule: instances.TaintRule{Taint: v1.Taint{Key: "example.com/a", Effect: "Sometimes"}},
+		},
+		{
+			name: "invalid pattern",
+			rule: instances.TaintRule{
+				InstanceType: "[",
+				Taint:        v1.Taint{Key: "example.com/a", Effect: v1.TaintEffectNoSchedule},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := instances.Config{TaintRules: []instances.TaintRule{tt.rule}}
+			config.ApplyDefaults()
+			require.ErrorIs(t, config.Validate(), instances.ErrInvalidTaintRule)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package instances
+
+import (
+	"context"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	attributeNodeName   = "k8s.node.name"
+	attributeProviderID = "crusoe.provider_id"
+	attributeDiskID     = "crusoe.disk_id"
+)
+
+// startSpan starts the span of a cloudprovider method. The API client calls made with
+// the returned context are its children.
+func startSpan(ctx context.Context, method string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	//nolint:spancheck // ended by endSpan
+	return tracing.Tracer().Start(ctx, "Instances."+method, trace.WithAttributes(attributes...))
+}
+
+// endSpan ends the span, recording the error the method returned.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func nodeAttributes(node *v1.Node) []attribute.KeyValue {
+	if node == nil {
+		return nil
+	}
+
+	return []attribute.KeyValue{
+		attribute.String(attributeNodeName, node.Name),
+		attribute.String(attributeProviderID, node.Spec.ProviderID),
+	}
+}
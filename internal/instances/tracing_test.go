@@ -0,0 +1,40 @@
+package instances_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	mock_client "github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client/mock"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/tracing"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+//nolint:paralleltest // sets the global tracer provider
+func TestInstancesTracing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Register(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	mockClient := mock_client.NewMockApiClient(ctrl)
+	mockClient.EXPECT().GetInstanceByID(gomock.Any(), TESTInstanceID).Return(testInstance(), nil, nil)
+	instanceService := instances.NewCrusoeInstances(client.NewInstrumentedAPIClient(mockClient))
+
+	exists, err := instanceService.InstanceExists(context.Background(), testNode())
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(t, spans, 2)
+	// spans are exported as they end, so the API client call comes first
+	require.Equal(t, "APIClient.GetInstanceByID", spans[0].Name())
+	require.Equal(t, "Instances.InstanceExists", spans[1].Name())
+	require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	require.Equal(t, spans[1].SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+}
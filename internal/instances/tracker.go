@@ -0,0 +1,99 @@
+package instances
+
+import (
+	"sync"
+	"time"
+)
+
+// notFoundEntry is the state kept for an instance that could not be found in the Crusoe API.
+type notFoundEntry struct {
+	// since is when the instance was first missing, zero if InstanceExists has not seen it missing.
+	since time.Time
+	// attempts is the number of InstanceShutdown lookups that could not find the instance.
+	attempts int
+}
+
+// notFoundTracker keeps the in-memory not found state of instances by provider ID.
+// Entries are dropped when the instance is found again or its node is deleted, so
+// the tracker does not grow with node churn and recycled provider IDs start fresh.
+type notFoundTracker struct {
+	mu      sync.Mutex
+	entries map[string]*notFoundEntry
+	// gracePeriod is how long an instance is reported as existing after it went missing.
+	gracePeriod time.Duration
+}
+
+func newNotFoundTracker(gracePeriod time.Duration) *notFoundTracker {
+	return &notFoundTracker{
+		entries:     make(map[string]*notFoundEntry),
+		gracePeriod: gracePeriod,
+	}
+}
+
+// since returns when the instance was first missing. earliest is used when it is before
+// the tracked time, or when the instance was not tracked yet.
+func (t *notFoundTracker) since(providerID string, earliest time.Time) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry := t.entry(providerID)
+	if entry.since.IsZero() || earliest.Before(entry.since) {
+		entry.since = earliest
+	}
+	t.updateMetrics()
+
+	return entry.since
+}
+
+// attempt records a failed lookup, starting from at least floor previous attempts, and
+// returns the new number of attempts. Counting stops once limit is exceeded.
+func (t *notFoundTracker) attempt(providerID string, floor, limit int) (attempts int, changed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry := t.entry(providerID)
+	entry.attempts = max(entry.attempts, floor)
+	if entry.attempts <= limit {
+		entry.attempts++
+		changed = true
+	}
+	t.updateMetrics()
+
+	return entry.attempts, changed
+}
+
+// forget drops all state of the instance.
+func (t *notFoundTracker) forget(providerID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, providerID)
+	t.updateMetrics()
+}
+
+func (t *notFoundTracker) entry(providerID string) *notFoundEntry {
+	entry, ok := t.entries[providerID]
+	if !ok {
+		entry = &notFoundEntry{}
+		t.entries[providerID] = entry
+	}
+
+	return entry
+}
+
+// updateMetrics must be called with the lock held. Instances missing for longer than the
+// grace period are no longer counted as in it, although they are tracked until their node
+// is deleted.
+func (t *notFoundTracker) updateMetrics() {
+	gracePeriod, shutdown := 0, 0
+	for _, entry := range t.entries {
+		if !entry.since.IsZero() && time.Since(entry.since) < t.gracePeriod {
+			gracePeriod++
+		}
+		if entry.attempts > 0 {
+			shutdown++
+		}
+	}
+	trackedNotFoundInstances.WithLabelValues(trackedStateGracePeriod).Set(float64(gracePeriod))
+	trackedNotFoundInstances.WithLabelValues(trackedStateShutdownAttempts).Set(float64(shutdown))
+}
@@ -0,0 +1,205 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DrainStartedAnnotation records when the controller started draining a node whose
+	// instance is gone, so the drain timeout survives controller restarts.
+	DrainStartedAnnotation = "crusoe.ai/node.drainStartedAt"
+	// DrainCordonedAnnotation marks a node that the controller cordoned for the drain, so
+	// that cancelling the drain does not uncordon a node an operator cordoned.
+	DrainCordonedAnnotation = "crusoe.ai/node.drainCordoned"
+
+	drainNodeEvent         = "DrainingNode"
+	drainTimeoutEvent      = "DrainTimedOut"
+	drainRequeueInterval   = 10 * time.Second
+	daemonSetOwnerKind     = "DaemonSet"
+	zeroGracePeriodSeconds = int64(0)
+)
+
+// errDrainInProgress is returned while pods are still being removed from a node.
+var errDrainInProgress = errors.New("node drain in progress")
+
+// drainNode cordons the node and removes its pods before the node is deleted. Pods
+// are evicted, honoring PodDisruptionBudgets, until the drain timeout has passed
+// since the drain started, after which they are force deleted. Evicted pods that are
+// still terminating are waited for, as their grace period is honored until the timeout.
+//
+// errDrainInProgress is returned until no pods are left on the node.
+func (c *CloudNodeLifecycleController) drainNode(ctx context.Context, node *v1.Node, reason string) error {
+	startedAt, err := c.startDrain(ctx, node, reason)
+	if err != nil {
+		return err
+	}
+	timedOut := time.Since(startedAt) >= c.drainTimeout
+
+	pods, err := c.kubeClient.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node.Name).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pods on node %s: %w", node.Name, err)
+	}
+
+	remaining, forced := 0, 0
+	for index := range pods.Items {
+		pod := &pods.Items[index]
+		if !drainable(pod) {
+			continue
+		}
+
+		switch {
+		case timedOut:
+			err = c.forceDeletePod(ctx, pod)
+			if err == nil {
+				forced++
+
+				continue
+			}
+		case pod.DeletionTimestamp == nil:
+			err = c.evictPod(ctx, pod)
+		}
+		if err != nil {
+			klog.ErrorS(err, "Failed to remove pod from node", "pod", klog.KObj(pod), "node", klog.KObj(node))
+		}
+		remaining++
+	}
+
+	if timedOut && forced > 0 {
+		c.recorder.Eventf(node, v1.EventTypeWarning, drainTimeoutEvent,
+			"Force deleted %d pods on node %s, drain did not finish within %s", forced, node.Name, c.drainTimeout)
+	}
+
+	if remaining == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d pods left on node %s", errDrainInProgress, remaining, node.Name)
+}
+
+// startDrain cordons the node and returns when its drain started, persisting the
+// start time the first time the node is drained. A node that is already cordoned is
+// left as it is and not marked as cordoned by the controller.
+func (c *CloudNodeLifecycleController) startDrain(ctx context.Context, node *v1.Node, reason string,
+) (time.Time, error) {
+	startedAt, started := drainStartedAt(node)
+	cordon := !node.Spec.Unschedulable
+	if started && !cordon {
+		return startedAt, nil
+	}
+
+	if !started {
+		startedAt = time.Now()
+	}
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`,
+		DrainStartedAnnotation, startedAt.UTC().Format(time.RFC3339))
+	if cordon {
+		patch = fmt.Sprintf(`{"metadata":{"annotations":{%q:%q,%q:"true"}},"spec":{"unschedulable":true}}`,
+			DrainStartedAnnotation, startedAt.UTC().Format(time.RFC3339), DrainCordonedAnnotation)
+	}
+	_, err := c.kubeClient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, []byte(patch),
+		metav1.PatchOptions{})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to cordon node %s: %w", node.Name, err)
+	}
+
+	if !started {
+		c.recorder.Eventf(node, v1.EventTypeNormal, drainNodeEvent,
+			"Draining node %s because %s", node.Name, reason)
+	}
+
+	return startedAt, nil
+}
+
+// cancelDrain stops the drain of a node that is not deleted after all, because its instance
+// turned out to be running again or a safeguard blocks its deletion. The node is only
+// uncordoned when the controller cordoned it for the drain.
+func (c *CloudNodeLifecycleController) cancelDrain(ctx context.Context, node *v1.Node) error {
+	if _, ok := node.Annotations[DrainStartedAnnotation]; !ok {
+		return nil
+	}
+
+	klog.InfoS("Node is not being deleted, cancelling drain", "node", klog.KObj(node))
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:null,%q:null}}}`,
+		DrainStartedAnnotation, DrainCordonedAnnotation)
+	if _, cordoned := node.Annotations[DrainCordonedAnnotation]; cordoned {
+		patch = fmt.Sprintf(`{"metadata":{"annotations":{%q:null,%q:null}},"spec":{"unschedulable":null}}`,
+			DrainStartedAnnotation, DrainCordonedAnnotation)
+	}
+	_, err := c.kubeClient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, []byte(patch),
+		metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to uncordon node %s: %w", node.Name, err)
+	}
+
+	return nil
+}
+
+func drainStartedAt(node *v1.Node) (time.Time, bool) {
+	value, ok := node.Annotations[DrainStartedAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	startedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		klog.InfoS("Ignoring invalid annotation", "node", klog.KObj(node), "annotation", DrainStartedAnnotation,
+			"value", value)
+
+		return time.Time{}, false
+	}
+
+	return startedAt, true
+}
+
+func (c *CloudNodeLifecycleController) evictPod(ctx context.Context, pod *v1.Pod) error {
+	err := c.kubeClient.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		// a PodDisruptionBudget blocking the eviction is retried until the drain times out
+		return fmt.Errorf("failed to evict pod: %w", err)
+	}
+
+	return nil
+}
+
+func (c *CloudNodeLifecycleController) forceDeletePod(ctx context.Context, pod *v1.Pod) error {
+	gracePeriod := zeroGracePeriodSeconds
+	err := c.kubeClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriod,
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to force delete pod: %w", err)
+	}
+
+	return nil
+}
+
+// drainable reports whether the pod has to be removed before the node is deleted.
+// Mirror pods cannot be removed through the API, DaemonSet pods are removed by the
+// garbage collector with the node, and finished pods do not block rescheduling.
+func drainable(pod *v1.Pod) bool {
+	if _, mirror := pod.Annotations[v1.MirrorPodAnnotationKey]; mirror {
+		return false
+	}
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return false
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == daemonSetOwnerKind {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,96 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	nodeutil "k8s.io/component-helpers/node/util"
+	"k8s.io/klog/v2"
+)
+
+// InstanceStateCondition reflects the state of the Crusoe instance backing a node, separately
+// from the readiness reported by the kubelet. It is true while the instance is running and its
+// reason is the raw instance state, e.g. STATE_RUNNING, or NotFound.
+const InstanceStateCondition v1.NodeConditionType = "CrusoeInstanceState"
+
+const (
+	instanceStateNotFound = "NotFound"
+	instanceStatesTimeout = time.Minute
+)
+
+// InstanceStateLister is implemented by cloud providers that can list the states of all
+// instances at once, by provider ID.
+type InstanceStateLister interface {
+	InstanceStates(ctx context.Context) (map[string]instances.InstanceState, error)
+}
+
+// refreshInstanceStates lists the instance states and updates the condition of every node
+// from them. It runs in its own loop so that the node checks never wait on the listing.
+func (c *CloudNodeLifecycleController) refreshInstanceStates(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, instanceStatesTimeout)
+	defer cancel()
+
+	states, err := c.stateLister.InstanceStates(ctx)
+	if err != nil {
+		klog.ErrorS(err, "Failed to list instance states")
+
+		return
+	}
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Error listing nodes from cache")
+
+		return
+	}
+	for _, node := range nodes {
+		c.updateInstanceStateCondition(node, states)
+	}
+}
+
+// updateInstanceStateCondition sets the instance state condition of the node from the listed
+// states. Errors are logged rather than returned, as the condition is informational.
+func (c *CloudNodeLifecycleController) updateInstanceStateCondition(node *v1.Node,
+	states map[string]instances.InstanceState,
+) {
+	if node.Spec.ProviderID == "" {
+		return
+	}
+	state, found := states[node.Spec.ProviderID]
+
+	condition := v1.NodeCondition{
+		Type:               InstanceStateCondition,
+		Status:             v1.ConditionFalse,
+		Reason:             state.State,
+		Message:            fmt.Sprintf("Crusoe instance is not running, its state is %s", state.State),
+		LastTransitionTime: metav1.Now(),
+	}
+	switch {
+	case !found:
+		condition.Reason = instanceStateNotFound
+		condition.Message = "Crusoe instance was not found"
+	case state.Running:
+		condition.Status = v1.ConditionTrue
+		condition.Message = "Crusoe instance is running"
+	}
+	// every change of the instance state is a transition, not only changes of the status
+	_, current := nodeutil.GetNodeCondition(&node.Status, InstanceStateCondition)
+	if current != nil && current.Status == condition.Status && current.Reason == condition.Reason {
+		// the node is only patched when the condition changed, not to refresh its heartbeat
+		if current.Message == condition.Message {
+			return
+		}
+		condition.LastTransitionTime = current.LastTransitionTime
+	} else {
+		klog.V(2).InfoS("Instance state changed", "node", klog.KObj(node), "state", condition.Reason)
+	}
+
+	if err := nodeutil.SetNodeCondition(c.kubeClient, types.NodeName(node.Name), condition); err != nil {
+		klog.ErrorS(err, "Failed to update instance state condition", "node", klog.KObj(node))
+	}
+}
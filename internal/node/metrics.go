@@ -0,0 +1,222 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/util/sets"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// The number of nodes in the instance not found grace period is exported by the
+// instances package as crusoe_instances_not_found_tracked{state="grace_period"}.
+const (
+	metricsNamespace = "crusoe"
+	metricsSubsystem = "node_lifecycle"
+
+	blockedReasonProtected      = "protected"
+	blockedReasonDryRun         = "dry_run"
+	blockedReasonCircuitBreaker = "circuit_breaker"
+
+	deletedReasonInstanceGone = "instance_gone"
+	deletedReasonShutdown     = "shutdown"
+
+	taintAdded   = "added"
+	taintRemoved = "removed"
+
+	lookupInstanceExists   = "instance_exists"
+	lookupInstanceShutdown = "instance_shutdown"
+	lookupDiskAttached     = "disk_attached"
+
+	lookupReasonNotFound = "not_found"
+	lookupReasonTimeout  = "timeout"
+	lookupReasonCanceled = "canceled"
+	lookupReasonOther    = "other"
+)
+
+//nolint:gochecknoglobals // metrics are registered once on the global registry
+var (
+	blockedNodeDeletions = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "deletions_blocked_total",
+			Help:           "Number of times a node whose instance is gone was not deleted, by the safeguard that blocked it.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"reason"},
+	)
+
+	cycleNodes = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "cycle_nodes",
+			Help:           "Number of nodes queued to be checked by the last monitoring cycle.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	cycleDuration = metrics.NewHistogram(
+		&metrics.HistogramOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "cycle_duration_seconds",
+			Help:           "Time from the start of a monitoring cycle until all its nodes were checked.",
+			Buckets:        metrics.ExponentialBuckets(0.1, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	nodesChecked = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "nodes_checked_total",
+			Help:           "Number of node checks against the cloud provider.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	nodesDeleted = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "nodes_deleted_total",
+			Help:           "Number of nodes deleted, by why they were deleted.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"reason"},
+	)
+
+	shutdownTaints = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "shutdown_taints_total",
+			Help:           "Number of shutdown taints added to and removed from nodes.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation"},
+	)
+
+	volumeAttachmentsCleanedUp = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "volume_attachments_cleaned_up_total",
+			Help:           "Number of volume attachments removed from deleted nodes.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	cloudLookupErrors = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "cloud_lookup_errors_total",
+			Help:           "Number of failed cloud provider lookups, by lookup and reason.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"lookup", "reason"},
+	)
+
+	metricRegistration sync.Once
+)
+
+// registerMetrics registers the metrics that are to be monitored.
+func registerMetrics() {
+	metricRegistration.Do(func() {
+		legacyregistry.MustRegister(blockedNodeDeletions)
+		legacyregistry.MustRegister(cycleNodes)
+		legacyregistry.MustRegister(cycleDuration)
+		legacyregistry.MustRegister(nodesChecked)
+		legacyregistry.MustRegister(nodesDeleted)
+		legacyregistry.MustRegister(shutdownTaints)
+		legacyregistry.MustRegister(volumeAttachmentsCleanedUp)
+		legacyregistry.MustRegister(cloudLookupErrors)
+	})
+}
+
+// recordLookupError counts a failed cloud provider lookup.
+func recordLookupError(lookup string, err error) {
+	reason := lookupReasonOther
+	switch {
+	case errors.Is(err, client.ErrInstanceNotFound), errors.Is(err, client.ErrDiskNotFound),
+		errors.Is(err, cloudprovider.InstanceNotFound):
+		reason = lookupReasonNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		reason = lookupReasonTimeout
+	case errors.Is(err, context.Canceled):
+		reason = lookupReasonCanceled
+	}
+	cloudLookupErrors.WithLabelValues(lookup, reason).Inc()
+}
+
+// monitoringCycle measures how long it takes until every node queued by a run of
+// MonitorNodes has been checked, and traces the cycle with the checks of its nodes
+// as children. A cycle still running when the next one starts is not measured.
+type monitoringCycle struct {
+	mu      sync.Mutex
+	start   time.Time
+	pending sets.Set[string]
+	span    trace.Span
+}
+
+func (m *monitoringCycle) begin(ctx context.Context, nodeNames []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.span != nil {
+		m.span.SetAttributes(attribute.Int("nodes.unchecked", m.pending.Len()))
+		m.span.End()
+	}
+	_, m.span = tracing.Tracer().Start(ctx, "NodeLifecycle.MonitorNodes",
+		trace.WithAttributes(attribute.Int("nodes.queued", len(nodeNames))))
+	m.start = time.Now()
+	m.pending = sets.New(nodeNames...)
+	cycleNodes.Set(float64(len(nodeNames)))
+	if len(nodeNames) == 0 {
+		m.end()
+	}
+}
+
+// context returns the context to check the node in, which is part of the cycle trace
+// when the node was queued by the running cycle.
+func (m *monitoringCycle) context(ctx context.Context, nodeName string) context.Context {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.span == nil || !m.pending.Has(nodeName) {
+		return ctx
+	}
+
+	return trace.ContextWithSpan(ctx, m.span)
+}
+
+func (m *monitoringCycle) checked(nodeName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.pending.Has(nodeName) {
+		return
+	}
+	m.pending.Delete(nodeName)
+	if m.pending.Len() == 0 {
+		m.end()
+	}
+}
+
+func (m *monitoringCycle) end() {
+	cycleDuration.Observe(time.Since(m.start).Seconds())
+	m.span.End()
+	m.span = nil
+}
@@ -6,21 +6,29 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	coreinformers "k8s.io/client-go/informers/core/v1"
+	storageinformers "k8s.io/client-go/informers/storage/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
 	v1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	cloudprovider "k8s.io/cloud-provider"
 	cloudproviderapi "k8s.io/cloud-provider/api"
-	cloudnodeutil "k8s.io/cloud-provider/node/helpers"
 	controllersmetrics "k8s.io/component-base/metrics/prometheus/controllers"
 	nodeutil "k8s.io/component-helpers/node/util"
 	"k8s.io/klog/v2"
@@ -28,6 +36,8 @@ import (
 
 const (
 	deleteNodeEvent = "DeletingNode"
+
+	reasonInstanceGone = "it does not exist in the cloud provider"
 )
 
 //nolint:gochecknoglobals // can't construct const structs
@@ -46,14 +56,47 @@ var (
 // CloudNodeLifecycleController is responsible for deleting/updating kubernetes
 // nodes that have been deleted/shutdown on the cloud provider.
 type CloudNodeLifecycleController struct {
-	kubeClient clientset.Interface
-	nodeLister v1lister.NodeLister
+	kubeClient  clientset.Interface
+	nodeLister  v1lister.NodeLister
+	nodesSynced cache.InformerSynced
+
+	volumeAttachmentIndexer cache.Indexer
+	volumeAttachmentsSynced cache.InformerSynced
+	// diskChecker confirms that disks of the csiDrivers are detached before their volume
+	// attachments are released. It is nil unless enabled.
+	diskChecker DiskAttachmentChecker
+	csiDrivers  sets.Set[string]
+	// stateLister lists the instance states for the InstanceStateCondition once per
+	// monitoring period. It is nil unless enabled.
+	stateLister InstanceStateLister
 
 	broadcaster record.EventBroadcaster
 	recorder    record.EventRecorder
 
 	cloud cloudprovider.Interface
 
+	// queue holds the names of the nodes to check. Nodes whose check failed are
+	// added back with a per node exponential backoff.
+	queue   workqueue.TypedRateLimitingInterface[string]
+	workers int
+
+	// drainBeforeDelete removes the pods of nodes whose instance is gone before deleting them,
+	// evicting them for up to drainTimeout.
+	drainBeforeDelete bool
+	drainTimeout      time.Duration
+
+	// shutdownTaint is applied to nodes whose instance is shut down. Such nodes are
+	// deleted once shut down for deleteShutdownAfter, unless it is zero.
+	shutdownTaint       *v1.Taint
+	deleteShutdownAfter time.Duration
+
+	// dryRun and breaker guard against deleting nodes by mistake, e.g. when the cloud
+	// provider wrongly reports every instance as gone.
+	dryRun  bool
+	breaker *deletionBreaker
+
+	cycle monitoringCycle
+
 	// Value controlling NodeController monitoring period, i.e. how often does NodeController
 	// check node status posted from kubelet. This value should be lower than nodeMonitorGracePeriod
 	// set in controller-manager
@@ -62,9 +105,11 @@ type CloudNodeLifecycleController struct {
 
 func NewCloudNodeLifecycleController(
 	nodeInformer coreinformers.NodeInformer,
+	volumeAttachmentInformer storageinformers.VolumeAttachmentInformer,
 	kubeClient clientset.Interface,
 	cloud cloudprovider.Interface,
 	nodeMonitorPeriod time.Duration,
+	options *Options,
 ) (*CloudNodeLifecycleController, error) {
 	eventBroadcaster := record.NewBroadcaster()
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "cloud-node-lifecycle-controller"})
@@ -77,19 +122,81 @@ func NewCloudNodeLifecycleController(
 		return nil, ErrNoCloudProvider
 	}
 
-	_, instancesSupported := cloud.Instances()
-	_, instancesV2Supported := cloud.InstancesV2()
+	instances, instancesSupported := cloud.Instances()
+	instancesV2, instancesV2Supported := cloud.InstancesV2()
 	if !instancesSupported && !instancesV2Supported {
 		return nil, ErrInstancesNotSupported
 	}
 
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	registerMetrics()
+
+	err := volumeAttachmentInformer.Informer().AddIndexers(cache.Indexers{
+		volumeAttachmentNodeIndex: indexVolumeAttachmentByNode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add volume attachment indexer: %w", err)
+	}
+
 	c := &CloudNodeLifecycleController{
-		kubeClient:        kubeClient,
-		nodeLister:        nodeInformer.Lister(),
-		broadcaster:       eventBroadcaster,
-		recorder:          recorder,
-		cloud:             cloud,
-		nodeMonitorPeriod: nodeMonitorPeriod,
+		kubeClient:  kubeClient,
+		nodeLister:  nodeInformer.Lister(),
+		nodesSynced: nodeInformer.Informer().HasSynced,
+
+		volumeAttachmentIndexer: volumeAttachmentInformer.Informer().GetIndexer(),
+		volumeAttachmentsSynced: volumeAttachmentInformer.Informer().HasSynced,
+		csiDrivers:              sets.New(options.CSIDrivers...),
+		broadcaster:             eventBroadcaster,
+		recorder:                recorder,
+		cloud:                   cloud,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[string](options.MinRetryDelay, options.MaxRetryDelay),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "cloud_node_lifecycle"},
+		),
+		workers:             options.Workers,
+		drainBeforeDelete:   options.DrainBeforeDelete,
+		drainTimeout:        options.DrainTimeout,
+		shutdownTaint:       shutdownTaintFor(v1.TaintEffect(options.ShutdownTaintEffect)),
+		deleteShutdownAfter: options.DeleteShutdownAfter,
+		dryRun:              options.DryRun,
+		breaker:             newDeletionBreaker(options),
+		nodeMonitorPeriod:   nodeMonitorPeriod,
+	}
+
+	if options.ConfirmDiskDetach {
+		var supported bool
+		if c.diskChecker, supported = instancesV2.(DiskAttachmentChecker); !supported {
+			c.diskChecker, supported = instances.(DiskAttachmentChecker)
+		}
+		if !supported {
+			klog.InfoS("Cloud provider cannot check disk attachments, not confirming disks are detached")
+		}
+	}
+
+	if options.InstanceStateCondition {
+		var supported bool
+		if c.stateLister, supported = instancesV2.(InstanceStateLister); !supported {
+			c.stateLister, supported = instances.(InstanceStateLister)
+		}
+		if !supported {
+			klog.InfoS("Cloud provider cannot report instance states, not setting the instance state condition",
+				"condition", InstanceStateCondition)
+		}
+	}
+
+	_, err = nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueNode,
+		UpdateFunc: func(oldObj, newObj any) {
+			// other changes are picked up by the periodic MonitorNodes resync
+			if readyStatus(oldObj) != readyStatus(newObj) {
+				c.enqueueNode(newObj)
+			}
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add node event handler: %w", err)
 	}
 
 	return c, nil
@@ -101,105 +208,288 @@ func (c *CloudNodeLifecycleController) Run(ctx context.Context,
 	controllerManagerMetrics *controllersmetrics.ControllerManagerMetrics,
 ) {
 	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
 	controllerManagerMetrics.ControllerStarted("cloud-node-lifecycle")
 	defer controllerManagerMetrics.ControllerStopped("cloud-node-lifecycle")
 
 	// Start event processing pipeline.
-	klog.Info("Sending events to api server")
+	klog.InfoS("Sending events to api server")
 	c.broadcaster.StartStructuredLogging(0)
 	c.broadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: c.kubeClient.CoreV1().Events("")})
 	defer c.broadcaster.Shutdown()
 
-	// The following loops run communicate with the APIServer with a worst case complexity
-	// of O(num_nodes) per cycle. These functions are justified here because these events fire
-	// very infrequently. DO NOT MODIFY this to perform frequent operations.
+	if !cache.WaitForCacheSync(ctx.Done(), c.nodesSynced, c.volumeAttachmentsSynced) {
+		klog.ErrorS(nil, "Failed to wait for node caches to sync")
 
-	// Start a loop to periodically check if any nodes have been
-	// deleted or shutdown from the cloudprovider
+		return
+	}
+
+	for range c.workers {
+		go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	}
+	if c.stateLister != nil {
+		go wait.UntilWithContext(ctx, c.refreshInstanceStates, c.nodeMonitorPeriod)
+	}
+
+	// The node informer only queues nodes when they are added or their readiness changes,
+	// so periodically queue every node to check if they have been deleted or shutdown
+	// from the cloudprovider.
 	wait.UntilWithContext(ctx, c.MonitorNodes, c.nodeMonitorPeriod)
 }
 
-// MonitorNodes checks to see if nodes in the cluster have been deleted
-// or shutdown. If deleted, it deletes the node resource. If shutdown it
-// applies a shutdown taint to the node.
-//
-//nolint:funlen,cyclop // copied from upstream
+// MonitorNodes queues every node in the cluster to be checked for deletion or
+// shutdown in the cloud provider. Nodes that are backing off after a failed
+// check are left to be retried by the queue.
 func (c *CloudNodeLifecycleController) MonitorNodes(ctx context.Context) {
 	nodes, err := c.nodeLister.List(labels.Everything())
 	if err != nil {
-		klog.Errorf("error listing nodes from cache: %s", err)
+		klog.ErrorS(err, "Error listing nodes from cache")
 
 		return
 	}
 
+	queued := make([]string, 0, len(nodes))
 	for _, node := range nodes {
-		// Default NodeReady status to v1.ConditionUnknown
-		status := v1.ConditionUnknown
-		if _, c := nodeutil.GetNodeCondition(&node.Status, v1.NodeReady); c != nil {
-			status = c.Status
+		if c.queue.NumRequeues(node.Name) > 0 {
+			continue
 		}
+		queued = append(queued, node.Name)
+	}
+	c.cycle.begin(ctx, queued)
+	for _, nodeName := range queued {
+		c.queue.Add(nodeName)
+	}
+}
 
-		if status == v1.ConditionTrue {
-			// if taint exist remove taint
-			err = cloudnodeutil.RemoveTaintOffNode(c.kubeClient, node.Name, node, ShutdownTaint)
-			if err != nil {
-				klog.Errorf("error patching node taints: %v", err)
-			}
+func (c *CloudNodeLifecycleController) enqueueNode(obj any) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return
+	}
+	c.queue.Add(node.Name)
+}
 
-			continue
+func (c *CloudNodeLifecycleController) runWorker(ctx context.Context) {
+	for c.processNextWorkItem(ctx) {
+	}
+}
+
+func (c *CloudNodeLifecycleController) processNextWorkItem(ctx context.Context) bool {
+	nodeName, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(nodeName)
+
+	syncCtx, span := tracing.Tracer().Start(c.cycle.context(ctx, nodeName), "NodeLifecycle.syncNode",
+		trace.WithAttributes(attribute.String("k8s.node.name", nodeName)))
+	err := c.syncNode(syncCtx, nodeName)
+	if err != nil && !errors.Is(err, errDrainInProgress) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	c.cycle.checked(nodeName)
+	if errors.Is(err, errDrainInProgress) {
+		// not a failure, so check on the drain again without backing off
+		klog.V(2).InfoS("Node drain in progress", "node", nodeName, "err", err, "requeueAfter", drainRequeueInterval)
+		c.queue.Forget(nodeName)
+		c.queue.AddAfter(nodeName, drainRequeueInterval)
+
+		return true
+	}
+	if err != nil {
+		klog.ErrorS(err, "Error checking node, requeuing", "node", nodeName)
+		c.queue.AddRateLimited(nodeName)
+
+		return true
+	}
+	c.queue.Forget(nodeName)
+
+	return true
+}
+
+// syncNode checks to see if the node has been deleted or shutdown in the cloud
+// provider. If deleted, it deletes the node resource. If shutdown it applies a
+// shutdown taint to the node. Errors are returned so that the node is retried.
+//
+//nolint:funlen,cyclop // copied from upstream
+func (c *CloudNodeLifecycleController) syncNode(ctx context.Context, nodeName string) error {
+	node, err := c.nodeLister.Get(nodeName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to get node %s from cache: %w", nodeName, err)
+	}
+	nodesChecked.Inc()
+
+	// Default NodeReady status to v1.ConditionUnknown
+	status := v1.ConditionUnknown
+	if _, c := nodeutil.GetNodeCondition(&node.Status, v1.NodeReady); c != nil {
+		status = c.Status
+	}
+
+	if status == v1.ConditionTrue {
+		if err := c.cancelDrain(ctx, node); err != nil {
+			return err
 		}
 
-		// At this point the node has NotReady status, we need to check if the node has been removed
-		// from the cloud provider. If node cannot be found in cloudprovider, then delete the node
-		exists, err := c.ensureNodeExistsByProviderID(ctx, node)
+		// if taint exist remove taint
+		err = c.removeShutdownTaints(node, ShutdownTaint, ShutdownNoExecuteTaint)
 		if err != nil {
-			klog.Errorf("error checking if node %s exists: %v", node.Name, err)
+			return err
+		}
 
-			continue
+		return c.clearShutdown(ctx, node)
+	}
+
+	// At this point the node has NotReady status, we need to check if the node has been removed
+	// from the cloud provider. If node cannot be found in cloudprovider, then delete the node
+	exists, err := c.ensureNodeExistsByProviderID(ctx, node)
+	if err != nil {
+		recordLookupError(lookupInstanceExists, err)
+
+		return fmt.Errorf("error checking if node %s exists: %w", node.Name, err)
+	}
+
+	if !exists {
+		// Current node does not exist, we should delete it, its taints do not matter anymore
+		return c.deleteNode(ctx, node, reasonInstanceGone, deletedReasonInstanceGone, true)
+	}
+
+	// Node exists. We need to check this to get taint working in similar in all cloudproviders
+	// current problem is that shutdown nodes are not working in similar way ie. all cloudproviders
+	// does not delete node from kubernetes cluster when instance it is shutdown see issue #46442
+	shutdown, err := c.shutdownInCloudProvider(ctx, node)
+	if err != nil {
+		recordLookupError(lookupInstanceShutdown, err)
+
+		return fmt.Errorf("error checking if node %s is shutdown: %w", node.Name, err)
+	}
+
+	if !shutdown {
+		if err := c.cancelDrain(ctx, node); err != nil {
+			return err
 		}
 
-		//nolint:nestif // not that complex
-		if !exists {
-			// Current node does not exist, we should delete it, its taints do not matter anymore
+		return c.clearShutdown(ctx, node)
+	}
 
-			klog.V(2).Infof("deleting node since it is no longer present in cloud provider: %s", node.Name)
+	// if node is shutdown add shutdown taint
+	since, err := c.markShutdown(ctx, node)
+	if err != nil {
+		return err
+	}
 
-			ref := &v1.ObjectReference{
-				Kind:      "Node",
-				Name:      node.Name,
-				UID:       node.UID,
-				Namespace: "",
-			}
+	if c.deleteShutdownAfter > 0 && time.Since(since) >= c.deleteShutdownAfter {
+		return c.deleteNode(ctx, node,
+			fmt.Sprintf("its instance has been shut down for longer than %s", c.deleteShutdownAfter),
+			deletedReasonShutdown, false)
+	}
 
-			c.recorder.Eventf(ref, v1.EventTypeNormal, deleteNodeEvent,
-				"Deleting node %s because it does not exist in the cloud provider", node.Name)
+	return nil
+}
 
-			err := CleanUpVolumeAttachmentsForNode(ctx, c.kubeClient, node.Name)
-			if err != nil {
-				klog.Errorf("failed to clean up volume attachments for node %s: %v", node.Name, err)
-			}
+// deleteNode deletes a node for the given reason, unless a safeguard blocks the deletion.
+// Deleted nodes are counted by metricReason. instanceGone is false for nodes whose instance
+// still exists but is shut down, whose disks are detached by the CSI attacher as usual.
+func (c *CloudNodeLifecycleController) deleteNode(ctx context.Context, node *v1.Node,
+	reason, metricReason string, instanceGone bool,
+) error {
+	if c.deletionBlocked(node, reason) {
+		// the node stays, so it must not be left cordoned by a drain started earlier
+		return c.cancelDrain(ctx, node)
+	}
 
-			if err := c.kubeClient.CoreV1().Nodes().Delete(ctx, node.Name, metav1.DeleteOptions{}); err != nil {
-				klog.Errorf("unable to delete node %q: %v", node.Name, err)
-			}
-		} else {
-			// Node exists. We need to check this to get taint working in similar in all cloudproviders
-			// current problem is that shutdown nodes are not working in similar way ie. all cloudproviders
-			// does not delete node from kubernetes cluster when instance it is shutdown see issue #46442
-			shutdown, err := c.shutdownInCloudProvider(ctx, node)
-			if err != nil {
-				klog.Errorf("error checking if node %s is shutdown: %v", node.Name, err)
-			}
+	klog.V(2).InfoS("Deleting node", "node", klog.KObj(node), "reason", reason)
 
-			if shutdown && err == nil {
-				// if node is shutdown add shutdown taint
-				err = cloudnodeutil.AddOrUpdateTaintOnNode(c.kubeClient, node.Name, ShutdownTaint)
-				if err != nil {
-					klog.Errorf("failed to apply shutdown taint to node %s, it may have been deleted.", node.Name)
-				}
-			}
+	ref := &v1.ObjectReference{
+		Kind:      "Node",
+		Name:      node.Name,
+		UID:       node.UID,
+		Namespace: "",
+	}
+
+	if c.drainBeforeDelete {
+		if err := c.drainNode(ctx, node, reason); err != nil {
+			return err
 		}
 	}
+
+	c.recorder.Eventf(ref, v1.EventTypeNormal, deleteNodeEvent,
+		"Deleting node %s because %s", node.Name, reason)
+
+	if err := c.cleanUpVolumeAttachments(ctx, node, instanceGone); err != nil {
+		if instanceGone {
+			return fmt.Errorf("failed to clean up volume attachments for node %s: %w", node.Name, err)
+		}
+		klog.ErrorS(err, "Failed to clean up volume attachments, deleting the node anyway", "node", klog.KObj(node))
+	}
+
+	if err := c.kubeClient.CoreV1().Nodes().Delete(ctx, node.Name, metav1.DeleteOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("unable to delete node %q: %w", node.Name, err)
+	}
+	nodesDeleted.WithLabelValues(metricReason).Inc()
+
+	return nil
+}
+
+// deletionBlocked reports whether the deletion protection annotation, dry-run mode or
+// the circuit breaker keep a node from being deleted. Blocked
+// deletions are recorded as events and counted, and retried on the next resync.
+func (c *CloudNodeLifecycleController) deletionBlocked(node *v1.Node, reason string) bool {
+	if deletionProtected(node) {
+		blockedNodeDeletions.WithLabelValues(blockedReasonProtected).Inc()
+		c.recorder.Eventf(node, v1.EventTypeNormal, deletionBlockedEvent,
+			"Not deleting node %s although %s, because of its %s annotation",
+			node.Name, reason, DeletionProtectionAnnotation)
+
+		return true
+	}
+
+	if c.dryRun {
+		blockedNodeDeletions.WithLabelValues(blockedReasonDryRun).Inc()
+		c.recorder.Eventf(node, v1.EventTypeNormal, deleteNodeEvent,
+			"Dry run: would delete node %s because %s", node.Name, reason)
+
+		return true
+	}
+
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Error listing nodes from cache, not deleting node", "node", klog.KObj(node))
+
+		return true
+	}
+	if !c.breaker.allow(node.Name, len(nodes), time.Now()) {
+		blockedNodeDeletions.WithLabelValues(blockedReasonCircuitBreaker).Inc()
+		c.recorder.Eventf(node, v1.EventTypeWarning, deletionBlockedEvent,
+			"Not deleting node %s although %s, because too many nodes were deleted recently",
+			node.Name, reason)
+
+		return true
+	}
+
+	return false
+}
+
+// readyStatus returns the status of the Ready condition of a node informer object.
+func readyStatus(obj any) v1.ConditionStatus {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return v1.ConditionUnknown
+	}
+	if _, condition := nodeutil.GetNodeCondition(&node.Status, v1.NodeReady); condition != nil {
+		return condition.Status
+	}
+
+	return v1.ConditionUnknown
 }
 
 // getProviderID returns the provider ID for the node. If Node CR has no provider ID,
@@ -0,0 +1,579 @@
+package node_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/node"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	cloudprovider "k8s.io/cloud-provider"
+	fakecloud "k8s.io/cloud-provider/fake"
+	"k8s.io/component-base/metrics/legacyregistry"
+	controllersmetrics "k8s.io/component-base/metrics/prometheus/controllers"
+)
+
+const testNodeName = "test-node"
+
+func notReadyNode() *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: testNodeName},
+		Spec:       v1.NodeSpec{ProviderID: "crusoe://test-instance-id"},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionFalse}},
+		},
+	}
+}
+
+func TestControllerDeletesNodeMissingFromCloud(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kubeClient := fake.NewClientset(notReadyNode())
+	startController(ctx, t, kubeClient, node.NewOptions())
+
+	// the node is processed from the queue long before the hourly resync
+	require.Eventually(t, func() bool {
+		_, err := kubeClient.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+
+		return apierrors.IsNotFound(err)
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestOptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, node.NewOptions().Validate())
+
+	options := node.NewOptions()
+	options.Workers = 0
+	require.ErrorIs(t, options.Validate(), node.ErrInvalidOptions)
+
+	options = node.NewOptions()
+	options.MaxRetryDelay = options.MinRetryDelay / 2
+	require.ErrorIs(t, options.Validate(), node.ErrInvalidOptions)
+
+	informerFactory := informers.NewSharedInformerFactory(fake.NewClientset(), 0)
+	_, err := node.NewCloudNodeLifecycleController(informerFactory.Core().V1().Nodes(),
+		informerFactory.Storage().V1().VolumeAttachments(), fake.NewClientset(), &fakecloud.Cloud{}, time.Minute,
+		options)
+	require.ErrorIs(t, err, node.ErrInvalidOptions)
+}
+
+func testPod(name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+		Spec:       v1.PodSpec{NodeName: testNodeName},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+func startController(ctx context.Context, t *testing.T, kubeClient *fake.Clientset, options *node.Options) {
+	t.Helper()
+
+	startControllerWithCloud(ctx, t, kubeClient, &fakecloud.Cloud{EnableInstancesV2: true}, options)
+}
+
+func startControllerWithCloud(ctx context.Context, t *testing.T, kubeClient *fake.Clientset,
+	cloud cloudprovider.Interface, options *node.Options,
+) {
+	t.Helper()
+
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	controller, err := node.NewCloudNodeLifecycleController(informerFactory.Core().V1().Nodes(),
+		informerFactory.Storage().V1().VolumeAttachments(), kubeClient, cloud, time.Hour, options)
+	require.NoError(t, err)
+
+	informerFactory.Start(ctx.Done())
+	go controller.Run(ctx, controllersmetrics.NewControllerManagerMetrics("test"))
+}
+
+func TestControllerDrainsNodeBeforeDeletion(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	daemonSetPod := testPod("daemonset-pod")
+	isController := true
+	daemonSetPod.OwnerReferences = []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "daemonset", Controller: &isController},
+	}
+	kubeClient := fake.NewClientset(notReadyNode(), testPod("pod"), daemonSetPod)
+	options := node.NewOptions()
+	options.DrainBeforeDelete = true
+	options.DrainTimeout = 0
+	startController(ctx, t, kubeClient, options)
+
+	require.Eventually(t, func() bool {
+		_, err := kubeClient.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+
+		return apierrors.IsNotFound(err)
+	}, 10*time.Second, 50*time.Millisecond)
+
+	_, err := kubeClient.CoreV1().Pods(metav1.NamespaceDefault).Get(ctx, "pod", metav1.GetOptions{})
+	require.True(t, apierrors.IsNotFound(err))
+	_, err = kubeClient.CoreV1().Pods(metav1.NamespaceDefault).Get(ctx, "daemonset-pod", metav1.GetOptions{})
+	require.NoError(t, err)
+}
+
+func TestControllerEvictsPodsWithinDrainTimeout(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// evicted pods that are still terminating are left to finish within the drain timeout
+	terminating := testPod("terminating-pod")
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	kubeClient := fake.NewClientset(notReadyNode(), testPod("pod"), terminating)
+	var forced atomic.Int32
+	kubeClient.PrependReactor("delete", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		forced.Add(1)
+
+		return false, nil, nil
+	})
+	evicted := make(chan string, 1)
+	kubeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction, _ := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		select {
+		case evicted <- eviction.Name:
+		default:
+		}
+
+		return true, nil, nil
+	})
+	options := node.NewOptions()
+	options.DrainBeforeDelete = true
+	options.DrainTimeout = time.Hour
+	startController(ctx, t, kubeClient, options)
+
+	select {
+	case name := <-evicted:
+		require.Equal(t, "pod", name)
+	case <-time.After(10 * time.Second):
+		t.Fatal("pod was not evicted")
+	}
+
+	// the node is kept cordoned until its pods are gone
+	cordoned, err := kubeClient.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.True(t, cordoned.Spec.Unschedulable)
+	require.Contains(t, cordoned.Annotations, node.DrainStartedAnnotation)
+	require.Contains(t, cordoned.Annotations, node.DrainCordonedAnnotation)
+	require.Never(t, func() bool { return forced.Load() > 0 }, time.Second, 50*time.Millisecond)
+}
+
+func TestControllerCancelsDrain(t *testing.T) {
+	t.Parallel()
+
+	for _, cordonedByDrain := range []bool{true, false} {
+		t.Run(fmt.Sprintf("cordonedByDrain=%t", cordonedByDrain), func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// the instance of a node being drained is running again
+			drained := notReadyNode()
+			drained.Status.Conditions[0].Status = v1.ConditionTrue
+			drained.Spec.Unschedulable = true
+			drained.Annotations = map[string]string{node.DrainStartedAnnotation: time.Now().Format(time.RFC3339)}
+			if cordonedByDrain {
+				drained.Annotations[node.DrainCordonedAnnotation] = "true"
+			}
+			kubeClient := fake.NewClientset(drained)
+			startController(ctx, t, kubeClient, node.NewOptions())
+
+			var current *v1.Node
+			require.Eventually(t, func() bool {
+				var err error
+				current, err = kubeClient.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+
+				return err == nil && current.Annotations[node.DrainStartedAnnotation] == ""
+			}, 10*time.Second, 50*time.Millisecond)
+
+			// a node cordoned by an operator before the drain started stays cordoned
+			require.NotContains(t, current.Annotations, node.DrainCordonedAnnotation)
+			require.Equal(t, !cordonedByDrain, current.Spec.Unschedulable)
+		})
+	}
+}
+
+func TestControllerCancelsDrainOfProtectedNode(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the node was being drained when its deletion protection annotation was added
+	drained := notReadyNode()
+	drained.Spec.Unschedulable = true
+	drained.Annotations = map[string]string{
+		node.DrainStartedAnnotation:       time.Now().Format(time.RFC3339),
+		node.DrainCordonedAnnotation:      "true",
+		node.DeletionProtectionAnnotation: "true",
+	}
+	kubeClient := fake.NewClientset(drained)
+	options := node.NewOptions()
+	options.DrainBeforeDelete = true
+	startController(ctx, t, kubeClient, options)
+
+	require.Eventually(t, func() bool {
+		current, err := kubeClient.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+
+		return err == nil && !current.Spec.Unschedulable &&
+			current.Annotations[node.DrainStartedAnnotation] == "" &&
+			current.Annotations[node.DrainCordonedAnnotation] == ""
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestControllerDeletionSafeguards(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		configure func(options *node.Options, nodes []*v1.Node)
+		deleted   int
+	}{
+		{
+			name: "deletion protection annotation",
+			configure: func(_ *node.Options, nodes []*v1.Node) {
+				nodes[0].Annotations = map[string]string{node.DeletionProtectionAnnotation: "true"}
+			},
+			deleted: 2,
+		},
+		{
+			name:      "dry run",
+			configure: func(options *node.Options, _ []*v1.Node) { options.DryRun = true },
+		},
+		{
+			name:      "max deletions",
+			configure: func(options *node.Options, _ []*v1.Node) { options.MaxDeletions = 1 },
+			deleted:   1,
+		},
+		{
+			name:      "max deletion percent",
+			configure: func(options *node.Options, _ []*v1.Node) { options.MaxDeletionPercent = 50 },
+			deleted:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			nodes := make([]*v1.Node, 3)
+			objects := make([]runtime.Object, len(nodes))
+			for index := range nodes {
+				nodes[index] = notReadyNode()
+				nodes[index].Name = fmt.Sprintf("node-%d", index)
+				objects[index] = nodes[index]
+			}
+			options := node.NewOptions()
+			tt.configure(options, nodes)
+			kubeClient := fake.NewClientset(objects...)
+			startController(ctx, t, kubeClient, options)
+
+			remaining := func() int {
+				list, err := kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+				require.NoError(t, err)
+
+				return len(list.Items)
+			}
+			require.Eventually(t, func() bool { return remaining() == len(nodes)-tt.deleted },
+				10*time.Second, 50*time.Millisecond)
+			// and no further nodes are deleted
+			time.Sleep(time.Second)
+			require.Equal(t, len(nodes)-tt.deleted, remaining())
+
+			if tt.name == "deletion protection annotation" {
+				_, err := kubeClient.CoreV1().Nodes().Get(ctx, "node-0", metav1.GetOptions{})
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestControllerShutdownNodes(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	longShutdown := notReadyNode()
+	longShutdown.Name = "long-shutdown"
+	longShutdown.Annotations = map[string]string{
+		node.ShutdownSinceAnnotation: time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+	}
+	kubeClient := fake.NewClientset(notReadyNode(), longShutdown)
+	options := node.NewOptions()
+	options.ShutdownTaintEffect = string(v1.TaintEffectNoExecute)
+	options.DeleteShutdownAfter = time.Hour
+	cloud := &fakecloud.Cloud{EnableInstancesV2: true, ExistsByProviderID: true, NodeShutdown: true}
+	startControllerWithCloud(ctx, t, kubeClient, cloud, options)
+
+	require.Eventually(t, func() bool {
+		_, err := kubeClient.CoreV1().Nodes().Get(ctx, longShutdown.Name, metav1.GetOptions{})
+
+		return apierrors.IsNotFound(err)
+	}, 10*time.Second, 50*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		tainted, err := kubeClient.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		return len(tainted.Spec.Taints) == 1 && tainted.Spec.Taints[0].MatchTaint(node.ShutdownNoExecuteTaint) &&
+			tainted.Annotations[node.ShutdownSinceAnnotation] != ""
+	}, 10*time.Second, 50*time.Millisecond)
+
+	require.GreaterOrEqual(t, metricValue(t, "crusoe_node_lifecycle_nodes_deleted_total", "reason", "shutdown"), 1.0)
+	require.GreaterOrEqual(t, metricValue(t, "crusoe_node_lifecycle_shutdown_taints_total", "operation", "added"), 1.0)
+}
+
+// diskCloud is a fake cloud provider that can check disk attachments.
+type diskCloud struct {
+	*fakecloud.Cloud
+
+	attached atomic.Bool
+}
+
+func (c *diskCloud) InstancesV2() (cloudprovider.InstancesV2, bool) { return c, true }
+
+func (c *diskCloud) DiskAttached(_ context.Context, diskID, providerID string) (bool, error) {
+	return diskID == "disk-id" && providerID == "crusoe://test-instance-id" && c.attached.Load(), nil
+}
+
+// stateCloud is a fake cloud provider that lists instance states.
+type stateCloud struct {
+	*fakecloud.Cloud
+
+	states map[string]instances.InstanceState
+	// blocked makes the listing hang until it is cancelled.
+	blocked bool
+}
+
+func (c *stateCloud) InstancesV2() (cloudprovider.InstancesV2, bool) { return c, true }
+
+func (c *stateCloud) InstanceStates(ctx context.Context) (map[string]instances.InstanceState, error) {
+	if c.blocked {
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	}
+
+	return c.states, nil
+}
+
+func TestControllerDoesNotWaitOnInstanceStates(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kubeClient := fake.NewClientset(notReadyNode())
+	cloud := &stateCloud{Cloud: &fakecloud.Cloud{EnableInstancesV2: true}, blocked: true}
+	startControllerWithCloud(ctx, t, kubeClient, cloud, node.NewOptions())
+
+	// the node missing from the cloud is deleted while the instance states are being listed
+	require.Eventually(t, func() bool {
+		_, err := kubeClient.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+
+		return apierrors.IsNotFound(err)
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestControllerSetsInstanceStateCondition(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stateNode := func(name string, ready v1.ConditionStatus) *v1.Node {
+		stateNode := notReadyNode()
+		stateNode.Name = name
+		stateNode.Spec.ProviderID = "crusoe://" + name
+		stateNode.Status.Conditions[0].Status = ready
+
+		return stateNode
+	}
+	transitioned := metav1.NewTime(time.Now().Add(-2 * time.Hour).Truncate(time.Second))
+	ready := stateNode("ready", v1.ConditionTrue)
+	ready.Status.Conditions = append(ready.Status.Conditions, v1.NodeCondition{
+		Type: node.InstanceStateCondition, Status: v1.ConditionTrue, Reason: "STATE_RUNNING",
+		Message: "Crusoe instance is running", LastHeartbeatTime: transitioned, LastTransitionTime: transitioned,
+	})
+	kubeClient := fake.NewClientset(stateNode("stopped", v1.ConditionFalse), stateNode("missing", v1.ConditionFalse),
+		ready)
+	var readyPatches atomic.Int32
+	kubeClient.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if patch, ok := action.(k8stesting.PatchAction); ok && patch.GetName() == "ready" &&
+			patch.GetSubresource() == "status" {
+			readyPatches.Add(1)
+		}
+
+		return false, nil, nil
+	})
+	cloud := &stateCloud{
+		Cloud: &fakecloud.Cloud{EnableInstancesV2: true, ExistsByProviderID: true, NodeShutdown: true},
+		states: map[string]instances.InstanceState{
+			"crusoe://stopped": {State: "STATE_SHUTOFF"},
+			"crusoe://ready":   {State: "STATE_RUNNING", Running: true},
+		},
+	}
+	startControllerWithCloud(ctx, t, kubeClient, cloud, node.NewOptions())
+
+	condition := func(name string) *v1.NodeCondition {
+		updated, err := kubeClient.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		require.NoError(t, err)
+		for index := range updated.Status.Conditions {
+			if updated.Status.Conditions[index].Type == node.InstanceStateCondition {
+				return &updated.Status.Conditions[index]
+			}
+		}
+
+		return nil
+	}
+	require.Eventually(t, func() bool {
+		stoppedCondition, missingCondition := condition("stopped"), condition("missing")
+
+		return stoppedCondition != nil && stoppedCondition.Status == v1.ConditionFalse &&
+			stoppedCondition.Reason == "STATE_SHUTOFF" &&
+			missingCondition != nil && missingCondition.Status == v1.ConditionFalse &&
+			missingCondition.Reason == "NotFound"
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// the unchanged condition of the ready node is not patched
+	require.Never(t, func() bool { return readyPatches.Load() > 0 }, time.Second, 50*time.Millisecond)
+	readyCondition := condition("ready")
+	require.True(t, readyCondition.LastHeartbeatTime.Equal(&transitioned))
+}
+
+func testVolumeAttachment(name, nodeName, attacher string) *storagev1.VolumeAttachment {
+	persistentVolumeName := "volume"
+
+	return &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Finalizers: []string{"external-attacher/ssd-csi-crusoe-ai"}},
+		Spec: storagev1.VolumeAttachmentSpec{
+			Attacher: attacher,
+			NodeName: nodeName,
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &persistentVolumeName},
+		},
+	}
+}
+
+func TestControllerCleansUpVolumeAttachments(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	persistentVolume := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "volume"},
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			CSI: &v1.CSIPersistentVolumeSource{Driver: "ssd.csi.crusoe.ai", VolumeHandle: "disk-id"},
+		}},
+	}
+	kubeClient := fake.NewClientset(notReadyNode(), persistentVolume,
+		testVolumeAttachment("csi", testNodeName, "ssd.csi.crusoe.ai"),
+		testVolumeAttachment("other-driver", testNodeName, "other.csi.example.com"),
+		testVolumeAttachment("other-node", "other-node", "ssd.csi.crusoe.ai"))
+	cloud := &diskCloud{Cloud: &fakecloud.Cloud{EnableInstancesV2: true}}
+	cloud.attached.Store(true)
+	options := node.NewOptions()
+	options.ConfirmDiskDetach = true
+	startControllerWithCloud(ctx, t, kubeClient, cloud, options)
+
+	volumeAttachments := func() []string {
+		list, err := kubeClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+		require.NoError(t, err)
+		names := make([]string, 0, len(list.Items))
+		for _, volumeAttachment := range list.Items {
+			names = append(names, volumeAttachment.Name)
+		}
+
+		return names
+	}
+
+	// the node is kept while its disk is attached, so the attachment is retried
+	require.Eventually(t, func() bool {
+		return len(volumeAttachments()) == 2
+	}, 10*time.Second, 50*time.Millisecond)
+	require.ElementsMatch(t, []string{"csi", "other-node"}, volumeAttachments())
+	_, err := kubeClient.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	cloud.attached.Store(false)
+	require.Eventually(t, func() bool {
+		_, err := kubeClient.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+
+		return apierrors.IsNotFound(err)
+	}, 20*time.Second, 50*time.Millisecond)
+	require.Equal(t, []string{"other-node"}, volumeAttachments())
+
+	require.GreaterOrEqual(t, metricValue(t, "crusoe_node_lifecycle_volume_attachments_cleaned_up_total", "", ""), 2.0)
+	require.GreaterOrEqual(t, metricValue(t, "crusoe_node_lifecycle_nodes_checked_total", "", ""), 1.0)
+}
+
+// metricValue sums the values of the counters or gauges with the name and, unless empty,
+// the label value, as exposed on the CCM /metrics endpoint.
+func TestControllerDeletesShutdownNodeWithAttachedDisk(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdownNode := notReadyNode()
+	shutdownNode.Annotations = map[string]string{
+		node.ShutdownSinceAnnotation: time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+	}
+	kubeClient := fake.NewClientset(shutdownNode, testVolumeAttachment("csi", testNodeName, "ssd.csi.crusoe.ai"))
+	cloud := &diskCloud{Cloud: &fakecloud.Cloud{EnableInstancesV2: true, ExistsByProviderID: true, NodeShutdown: true}}
+	cloud.attached.Store(true)
+	options := node.NewOptions()
+	options.ConfirmDiskDetach = true
+	options.DeleteShutdownAfter = time.Hour
+	startControllerWithCloud(ctx, t, kubeClient, cloud, options)
+
+	// the disk stays attached to the stopped instance, which does not keep the node from being deleted
+	require.Eventually(t, func() bool {
+		_, err := kubeClient.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+
+		return apierrors.IsNotFound(err)
+	}, 10*time.Second, 50*time.Millisecond)
+	_, err := kubeClient.StorageV1().VolumeAttachments().Get(ctx, "csi", metav1.GetOptions{})
+	require.True(t, apierrors.IsNotFound(err))
+}
+
+func metricValue(t *testing.T, name, label, value string) float64 {
+	t.Helper()
+
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	sum := 0.0
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			matches := label == ""
+			for _, pair := range metric.GetLabel() {
+				matches = matches || (pair.GetName() == label && pair.GetValue() == value)
+			}
+			if matches {
+				sum += metric.GetCounter().GetValue() + metric.GetGauge().GetValue()
+			}
+		}
+	}
+
+	return sum
+}
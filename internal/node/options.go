@@ -0,0 +1,131 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultWorkers        = 4
+	defaultMinRetryDelay  = 5 * time.Second
+	defaultMaxRetryDelay  = 5 * time.Minute
+	defaultDrainTimeout   = 5 * time.Minute
+	defaultDeletionWindow = 10 * time.Minute
+)
+
+var ErrInvalidOptions = errors.New("invalid node lifecycle controller options")
+
+// Options are the command line options of the Crusoe node lifecycle controller.
+type Options struct {
+	// Workers is the number of nodes that are checked concurrently.
+	Workers int
+	// MinRetryDelay and MaxRetryDelay bound the per node exponential backoff after errors.
+	MinRetryDelay time.Duration
+	MaxRetryDelay time.Duration
+	// DrainBeforeDelete cordons and drains nodes whose instance is gone before deleting them.
+	DrainBeforeDelete bool
+	// DrainTimeout is how long pods are evicted before they are force deleted.
+	DrainTimeout time.Duration
+	// ShutdownTaintEffect is the effect of the taint applied to nodes whose instance is shut
+	// down, NoSchedule or NoExecute.
+	ShutdownTaintEffect string
+	// DeleteShutdownAfter deletes nodes whose instance has been shut down this long. Zero
+	// keeps them.
+	DeleteShutdownAfter time.Duration
+	// ConfirmDiskDetach confirms through the cloud provider that the disks of volume
+	// attachments of the CSIDrivers are detached before the attachments are released.
+	ConfirmDiskDetach bool
+	CSIDrivers        []string
+	// DryRun only records the events of nodes that would be deleted instead of deleting them.
+	DryRun bool
+	// MaxDeletions and MaxDeletionPercent limit how many nodes, or which percentage of the
+	// nodes, are deleted within DeletionWindow. Zero disables the limit.
+	MaxDeletions       int
+	MaxDeletionPercent int
+	DeletionWindow     time.Duration
+	// InstanceStateCondition sets the CrusoeInstanceState condition of every node on every check.
+	InstanceStateCondition bool
+}
+
+// NewOptions returns Options with default values.
+func NewOptions() *Options {
+	return &Options{
+		Workers:       defaultWorkers,
+		MinRetryDelay: defaultMinRetryDelay,
+		MaxRetryDelay: defaultMaxRetryDelay,
+		DrainTimeout:  defaultDrainTimeout,
+
+		ShutdownTaintEffect:    string(v1.TaintEffectNoSchedule),
+		CSIDrivers:             []string{"ssd.csi.crusoe.ai", "fs.csi.crusoe.ai"},
+		DeletionWindow:         defaultDeletionWindow,
+		InstanceStateCondition: true,
+	}
+}
+
+// AddFlags adds the node lifecycle controller flags to the flag set.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.IntVar(&o.Workers, "node-lifecycle-workers", o.Workers,
+		"Number of nodes the cloud node lifecycle controller checks concurrently.")
+	fs.DurationVar(&o.MinRetryDelay, "node-lifecycle-min-retry-delay", o.MinRetryDelay,
+		"Initial delay before a node whose check failed is checked again.")
+	fs.DurationVar(&o.MaxRetryDelay, "node-lifecycle-max-retry-delay", o.MaxRetryDelay,
+		"Maximum delay before a node whose check keeps failing is checked again.")
+	fs.BoolVar(&o.DrainBeforeDelete, "node-lifecycle-drain-before-delete", o.DrainBeforeDelete,
+		"Cordon nodes whose instance no longer exists and remove their pods before deleting the nodes.")
+	fs.DurationVar(&o.DrainTimeout, "node-lifecycle-drain-timeout", o.DrainTimeout,
+		"How long pods are evicted from a node being drained before they are force deleted.")
+	fs.StringVar(&o.ShutdownTaintEffect, "node-lifecycle-shutdown-taint-effect", o.ShutdownTaintEffect,
+		"Effect of the taint applied to nodes whose instance is shut down, NoSchedule or NoExecute.")
+	fs.DurationVar(&o.DeleteShutdownAfter, "node-lifecycle-delete-shutdown-after", o.DeleteShutdownAfter,
+		"Delete nodes whose instance has been shut down for this long. 0 keeps them.")
+	fs.BoolVar(&o.ConfirmDiskDetach, "node-lifecycle-confirm-disk-detach", o.ConfirmDiskDetach,
+		"Confirm through the Crusoe API that the disks of deleted nodes are detached before removing the "+
+			"finalizers of their volume attachments.")
+	fs.StringSliceVar(&o.CSIDrivers, "node-lifecycle-csi-drivers", o.CSIDrivers,
+		"Names of the Crusoe CSI drivers whose volume attachments are checked with --node-lifecycle-confirm-disk-detach.")
+	fs.BoolVar(&o.DryRun, "node-lifecycle-dry-run", o.DryRun,
+		"Only record events for nodes the cloud node lifecycle controller would delete, without deleting them.")
+	fs.IntVar(&o.MaxDeletions, "node-lifecycle-max-deletions", o.MaxDeletions,
+		"Maximum number of nodes deleted within --node-lifecycle-deletion-window. 0 means no limit.")
+	fs.IntVar(&o.MaxDeletionPercent, "node-lifecycle-max-deletion-percent", o.MaxDeletionPercent,
+		"Maximum percentage of the nodes deleted within --node-lifecycle-deletion-window. 0 means no limit.")
+	fs.DurationVar(&o.DeletionWindow, "node-lifecycle-deletion-window", o.DeletionWindow,
+		"Time window the node deletion limits apply to.")
+	fs.BoolVar(&o.InstanceStateCondition, "node-lifecycle-instance-state-condition", o.InstanceStateCondition,
+		"Set the CrusoeInstanceState condition of every node from the instance states listed on every node "+
+			"monitor period.")
+}
+
+// Validate returns an error if the options cannot be used.
+func (o *Options) Validate() error {
+	if o.Workers < 1 {
+		return fmt.Errorf("%w: --node-lifecycle-workers must be at least 1", ErrInvalidOptions)
+	}
+	if o.MinRetryDelay <= 0 || o.MaxRetryDelay < o.MinRetryDelay {
+		return fmt.Errorf("%w: retry delays must be positive and the maximum must not be below the minimum",
+			ErrInvalidOptions)
+	}
+	if o.DrainTimeout < 0 {
+		return fmt.Errorf("%w: --node-lifecycle-drain-timeout must not be negative", ErrInvalidOptions)
+	}
+	if o.ShutdownTaintEffect != string(v1.TaintEffectNoSchedule) &&
+		o.ShutdownTaintEffect != string(v1.TaintEffectNoExecute) {
+		return fmt.Errorf("%w: --node-lifecycle-shutdown-taint-effect must be NoSchedule or NoExecute",
+			ErrInvalidOptions)
+	}
+	if o.DeleteShutdownAfter < 0 {
+		return fmt.Errorf("%w: --node-lifecycle-delete-shutdown-after must not be negative", ErrInvalidOptions)
+	}
+	if o.MaxDeletions < 0 || o.MaxDeletionPercent < 0 || o.MaxDeletionPercent > 100 {
+		return fmt.Errorf("%w: deletion limits must be positive and percentages at most 100", ErrInvalidOptions)
+	}
+	if (o.MaxDeletions > 0 || o.MaxDeletionPercent > 0) && o.DeletionWindow <= 0 {
+		return fmt.Errorf("%w: --node-lifecycle-deletion-window must be positive", ErrInvalidOptions)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DeletionProtectionAnnotation set to "true" keeps the controller from deleting the
+	// node, even when its instance no longer exists.
+	DeletionProtectionAnnotation = "crusoe.ai/node.deletionProtection"
+
+	deletionBlockedEvent = "NodeDeletionBlocked"
+	percent              = 100
+)
+
+// deletionProtected reports whether the node is protected from deletion.
+func deletionProtected(node *v1.Node) bool {
+	value, ok := node.Annotations[DeletionProtectionAnnotation]
+	if !ok {
+		return false
+	}
+	protected, err := strconv.ParseBool(value)
+	if err != nil {
+		// err on the side of keeping the node
+		klog.InfoS("Invalid annotation, treating node as protected", "node", klog.KObj(node),
+			"annotation", DeletionProtectionAnnotation, "value", value)
+
+		return true
+	}
+
+	return protected
+}
+
+// deletionBreaker is a circuit breaker limiting how many nodes are deleted within a
+// time window, so an API returning no instances cannot empty the cluster.
+type deletionBreaker struct {
+	mu         sync.Mutex
+	window     time.Duration
+	maxNodes   int
+	maxPercent int
+	// deletions maps the nodes deleted within the window to when deleting them was allowed.
+	deletions map[string]time.Time
+	// peakNodes is the largest cluster size seen within the window. Percentages are taken
+	// of it, so the limit does not shrink as nodes are deleted.
+	peakNodes int
+}
+
+func newDeletionBreaker(options *Options) *deletionBreaker {
+	return &deletionBreaker{
+		window:     options.DeletionWindow,
+		maxNodes:   options.MaxDeletions,
+		maxPercent: options.MaxDeletionPercent,
+		deletions:  make(map[string]time.Time),
+	}
+}
+
+// allow reports whether the node may be deleted given the number of nodes in the
+// cluster, recording the deletion if so. A node that was already allowed within the
+// window, e.g. because it is being drained, stays allowed.
+func (b *deletionBreaker) allow(nodeName string, totalNodes int, now time.Time) bool {
+	if b.maxNodes <= 0 && b.maxPercent <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for name, allowedAt := range b.deletions {
+		if now.Sub(allowedAt) >= b.window {
+			delete(b.deletions, name)
+		}
+	}
+	if _, ok := b.deletions[nodeName]; ok {
+		return true
+	}
+	if len(b.deletions) == 0 {
+		b.peakNodes = 0
+	}
+	b.peakNodes = max(b.peakNodes, totalNodes)
+
+	limit := b.maxNodes
+	if b.maxPercent > 0 {
+		// round up, so small clusters can still delete a node
+		fromPercent := (b.peakNodes*b.maxPercent + percent - 1) / percent
+		if limit <= 0 || fromPercent < limit {
+			limit = fromPercent
+		}
+	}
+	if len(b.deletions) >= limit {
+		return false
+	}
+	b.deletions[nodeName] = now
+
+	return true
+}
@@ -0,0 +1,120 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+	cloudnodeutil "k8s.io/cloud-provider/node/helpers"
+	"k8s.io/klog/v2"
+)
+
+// ShutdownSinceAnnotation records when the instance of a node was first seen shut down.
+const ShutdownSinceAnnotation = "crusoe.ai/node.shutdownSince"
+
+//nolint:gochecknoglobals // can't construct const structs
+var ShutdownNoExecuteTaint = &v1.Taint{
+	Key:    cloudproviderapi.TaintNodeShutdown,
+	Effect: v1.TaintEffectNoExecute,
+}
+
+// shutdownTaintFor returns the shutdown taint with the given effect.
+func shutdownTaintFor(effect v1.TaintEffect) *v1.Taint {
+	if effect == v1.TaintEffectNoExecute {
+		return ShutdownNoExecuteTaint
+	}
+
+	return ShutdownTaint
+}
+
+// markShutdown taints a node whose instance is shut down and returns since when the
+// instance has been shut down, recording it the first time.
+func (c *CloudNodeLifecycleController) markShutdown(ctx context.Context, node *v1.Node) (time.Time, error) {
+	if !hasTaint(node, c.shutdownTaint) {
+		err := cloudnodeutil.AddOrUpdateTaintOnNode(c.kubeClient, node.Name, c.shutdownTaint)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to apply shutdown taint to node %s, it may have been deleted: %w",
+				node.Name, err)
+		}
+		shutdownTaints.WithLabelValues(taintAdded).Inc()
+	}
+	// the shutdown taint effect may have been changed since the node was tainted
+	for _, taint := range []*v1.Taint{ShutdownTaint, ShutdownNoExecuteTaint} {
+		if taint == c.shutdownTaint {
+			continue
+		}
+		if err := c.removeShutdownTaints(node, taint); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if value, ok := node.Annotations[ShutdownSinceAnnotation]; ok {
+		since, err := time.Parse(time.RFC3339, value)
+		if err == nil {
+			return since, nil
+		}
+		klog.InfoS("Ignoring invalid annotation", "node", klog.KObj(node), "annotation", ShutdownSinceAnnotation,
+			"value", value)
+	}
+
+	since := time.Now()
+	if err := c.patchShutdownSince(ctx, node, fmt.Sprintf("%q", since.UTC().Format(time.RFC3339))); err != nil {
+		return time.Time{}, err
+	}
+
+	return since, nil
+}
+
+// removeShutdownTaints removes the shutdown taints the node has.
+func (c *CloudNodeLifecycleController) removeShutdownTaints(node *v1.Node, taints ...*v1.Taint) error {
+	present := make([]*v1.Taint, 0, len(taints))
+	for _, taint := range taints {
+		if hasTaint(node, taint) {
+			present = append(present, taint)
+		}
+	}
+	if len(present) == 0 {
+		return nil
+	}
+
+	if err := cloudnodeutil.RemoveTaintOffNode(c.kubeClient, node.Name, node, present...); err != nil {
+		return fmt.Errorf("error patching node taints: %w", err)
+	}
+	shutdownTaints.WithLabelValues(taintRemoved).Add(float64(len(present)))
+
+	return nil
+}
+
+func hasTaint(node *v1.Node, taint *v1.Taint) bool {
+	for index := range node.Spec.Taints {
+		if node.Spec.Taints[index].MatchTaint(taint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clearShutdown forgets when the instance of a node was shut down once it no longer is.
+func (c *CloudNodeLifecycleController) clearShutdown(ctx context.Context, node *v1.Node) error {
+	if _, ok := node.Annotations[ShutdownSinceAnnotation]; !ok {
+		return nil
+	}
+
+	return c.patchShutdownSince(ctx, node, "null")
+}
+
+func (c *CloudNodeLifecycleController) patchShutdownSince(ctx context.Context, node *v1.Node, value string) error {
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%s}}}`, ShutdownSinceAnnotation, value)
+	_, err := c.kubeClient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, []byte(patch),
+		metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update %s annotation of node %s: %w", ShutdownSinceAnnotation, node.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+const volumeAttachmentNodeIndex = "spec.nodeName"
+
+var (
+	ErrDiskStillAttached = errors.New("disk is still attached to the instance")
+	errUnexpectedObject  = errors.New("unexpected object in volume attachment index")
+	errNoDiskID          = errors.New("cannot determine the disk of the volume attachment")
+)
+
+// DiskAttachmentChecker is implemented by cloud providers that can tell whether a disk
+// is still attached to the instance with a provider ID.
+type DiskAttachmentChecker interface {
+	DiskAttached(ctx context.Context, diskID, providerID string) (bool, error)
+}
+
+// indexVolumeAttachmentByNode indexes volume attachments by the node they attach to.
+func indexVolumeAttachmentByNode(obj any) ([]string, error) {
+	volumeAttachment, ok := obj.(*storagev1.VolumeAttachment)
+	if !ok {
+		return nil, nil
+	}
+
+	return []string{volumeAttachment.Spec.NodeName}, nil
+}
+
+// cleanUpVolumeAttachments removes the volume attachments of a node that is about to be
+// deleted, returning the errors of all attachments that could not be removed. Disks are
+// only confirmed to be detached when confirmDetach is set, i.e. when the instance is gone.
+func (c *CloudNodeLifecycleController) cleanUpVolumeAttachments(ctx context.Context, node *v1.Node,
+	confirmDetach bool,
+) error {
+	objects, err := c.volumeAttachmentIndexer.ByIndex(volumeAttachmentNodeIndex, node.Name)
+	if err != nil {
+		return fmt.Errorf("failed to look up volume attachments of node %s: %w", node.Name, err)
+	}
+
+	errs := make([]error, 0, len(objects))
+	for _, object := range objects {
+		volumeAttachment, ok := object.(*storagev1.VolumeAttachment)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: %T", errUnexpectedObject, object))
+
+			continue
+		}
+		if err := c.removeVolumeAttachment(ctx, node, volumeAttachment, confirmDetach); err != nil {
+			errs = append(errs, fmt.Errorf("volume attachment %s: %w", volumeAttachment.Name, err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// removeVolumeAttachment deletes a volume attachment. With confirmDetach, attachments of
+// the Crusoe CSI driver are only released once the Crusoe API confirms that their disk is
+// detached from the instance, after which the attacher's finalizers are removed, as no
+// attacher will detach the disk from an instance that is gone. The disks of a shut down
+// instance stay attached until the attacher detaches them, so they are not checked.
+func (c *CloudNodeLifecycleController) removeVolumeAttachment(ctx context.Context, node *v1.Node,
+	volumeAttachment *storagev1.VolumeAttachment, confirmDetach bool,
+) error {
+	if confirmDetach && c.diskChecker != nil && c.csiDrivers.Has(volumeAttachment.Spec.Attacher) {
+		diskID, err := c.diskID(ctx, volumeAttachment)
+		if err != nil {
+			return err
+		}
+		attached, err := c.diskChecker.DiskAttached(ctx, diskID, node.Spec.ProviderID)
+		if err != nil {
+			recordLookupError(lookupDiskAttached, err)
+
+			return fmt.Errorf("failed to check if disk %s is attached: %w", diskID, err)
+		}
+		if attached {
+			return fmt.Errorf("%w: disk %s", ErrDiskStillAttached, diskID)
+		}
+
+		if len(volumeAttachment.Finalizers) > 0 {
+			klog.InfoS("Removing finalizers of volume attachment, disk is detached",
+				"volumeAttachment", klog.KObj(volumeAttachment), "node", klog.KObj(node), "diskID", diskID)
+			_, err = c.kubeClient.StorageV1().VolumeAttachments().Patch(ctx, volumeAttachment.Name,
+				types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`), metav1.PatchOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to remove finalizers: %w", err)
+			}
+		}
+	}
+
+	if volumeAttachment.DeletionTimestamp != nil {
+		volumeAttachmentsCleanedUp.Inc()
+
+		return nil
+	}
+
+	klog.InfoS("Deleting volume attachment", "volumeAttachment", klog.KObj(volumeAttachment), "node", klog.KObj(node))
+	err := c.kubeClient.StorageV1().VolumeAttachments().Delete(ctx, volumeAttachment.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+	volumeAttachmentsCleanedUp.Inc()
+
+	return nil
+}
+
+// diskID returns the ID of the Crusoe disk of a CSI volume attachment, which is the
+// volume handle of its persistent volume.
+func (c *CloudNodeLifecycleController) diskID(ctx context.Context,
+	volumeAttachment *storagev1.VolumeAttachment,
+) (string, error) {
+	source := volumeAttachment.Spec.Source
+	if source.InlineVolumeSpec != nil && source.InlineVolumeSpec.CSI != nil {
+		return source.InlineVolumeSpec.CSI.VolumeHandle, nil
+	}
+	if source.PersistentVolumeName == nil {
+		return "", fmt.Errorf("%w: no persistent volume", errNoDiskID)
+	}
+
+	persistentVolume, err := c.kubeClient.CoreV1().PersistentVolumes().Get(ctx, *source.PersistentVolumeName,
+		metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get persistent volume %s: %w", *source.PersistentVolumeName, err)
+	}
+	if persistentVolume.Spec.CSI == nil {
+		return "", fmt.Errorf("%w: persistent volume %s is not a CSI volume", errNoDiskID, persistentVolume.Name)
+	}
+
+	return persistentVolume.Spec.CSI.VolumeHandle, nil
+}
@@ -0,0 +1,93 @@
+// Package tracing sets up optional OpenTelemetry tracing of the cloud controller manager.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// InstrumentationName names the tracer of the cloud controller manager.
+	InstrumentationName = "github.com/crusoecloud/crusoe-cloud-controller-manager"
+
+	envSDKDisabled    = "OTEL_SDK_DISABLED"
+	envEndpoint       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	envTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+
+	serviceNameKey     = "service.name"
+	defaultServiceName = "crusoe-cloud-controller-manager"
+)
+
+// Enabled reports whether the standard OTEL environment variables configure an OTLP
+// endpoint to export traces to.
+func Enabled() bool {
+	if disabled, _ := strconv.ParseBool(os.Getenv(envSDKDisabled)); disabled {
+		return false
+	}
+
+	return os.Getenv(envEndpoint) != "" || os.Getenv(envTracesEndpoint) != ""
+}
+
+// Setup exports traces over OTLP/gRPC when Enabled, configured by the standard OTEL
+// environment variables, and propagates W3C trace context to the Crusoe API. The
+// returned function flushes and stops the exporter.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default service name
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String(serviceNameKey, defaultServiceName)))
+	if err == nil {
+		res, err = resource.Merge(res, resource.Environment())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	Register(sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)))
+	klog.InfoS("Exporting OpenTelemetry traces")
+
+	return func(ctx context.Context) error {
+		provider, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+		if !ok {
+			return nil
+		}
+		if err := provider.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shut down tracer provider: %w", err)
+		}
+
+		return nil
+	}, nil
+}
+
+// Register makes the tracer provider the global one and propagates W3C trace context
+// and baggage. Tests register a provider with an in-memory exporter.
+func Register(provider trace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Tracer returns the tracer of the cloud controller manager. It follows the global
+// tracer provider, so it can be created before tracing is set up.
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
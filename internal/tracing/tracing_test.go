@@ -0,0 +1,44 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/tracing"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+//nolint:paralleltest // sets environment variables and the global tracer provider
+func TestSetupDisabledWithoutEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	require.False(t, tracing.Enabled())
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4317")
+	require.True(t, tracing.Enabled())
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+	require.False(t, tracing.Enabled())
+
+	shutdown, err := tracing.Setup(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+}
+
+//nolint:paralleltest // sets the global tracer provider
+func TestTracerFollowsRegisteredProvider(t *testing.T) {
+	// created before the provider is registered, like the package level tracers
+	tracer := tracing.Tracer()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Register(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	_, span := tracer.Start(context.Background(), "test")
+	span.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "test", spans[0].Name)
+	require.Equal(t, tracing.InstrumentationName, spans[0].InstrumentationScope.Name)
+}
@@ -0,0 +1,110 @@
+// Package autoscaler is the Crusoe instance group backend of a cluster-autoscaler cloud
+// provider. It does not implement the cluster-autoscaler cloudprovider.CloudProvider and
+// cloudprovider.NodeGroup interfaces itself: the cluster-autoscaler module pins its own
+// Kubernetes dependencies and cannot be imported next to the ones of this module.
+//
+// CloudProvider and NodeGroup follow the method names and semantics of the upstream
+// interfaces, and a crusoe cloud provider in the cluster-autoscaler tree adapts them.
+// Compatibility is only checked there, by compile-time assertions of the adapter against
+// the upstream interfaces. The adapter is expected to:
+//
+//   - forward every method of CloudProvider and NodeGroup one to one, converting Instance
+//     and InstanceStatus to the cluster-autoscaler types;
+//   - build TemplateNodeInfo from TemplateNode;
+//   - stub the methods that use cluster-autoscaler types (Pricing, GetResourceLimiter,
+//     GetOptions, GetNodeGpuConfig) with cloudprovider.ErrNotImplemented or the defaults;
+//   - map ErrNotImplemented to cloudprovider.ErrNotImplemented.
+//
+// NewCloudProvider takes the same credentials as the cloud controller manager. The project
+// is read from the CRUSOE_PROJECT_ID environment variable, like in the cloud controller manager.
+package autoscaler
+
+import (
+	"errors"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// ErrNotImplemented is returned by the operations Crusoe instance groups do not support.
+var ErrNotImplemented = errors.New("not implemented")
+
+// CloudProvider is adapted to the cluster-autoscaler cloudprovider.CloudProvider interface.
+type CloudProvider interface {
+	// Name returns the name of the cloud provider.
+	Name() string
+	// NodeGroups returns the node groups found at the last Refresh.
+	NodeGroups() []NodeGroup
+	// NodeGroupForNode returns the node group of the node, nil if it is not autoscaled.
+	NodeGroupForNode(node *v1.Node) (NodeGroup, error)
+	// HasInstance reports whether the node has an instance in the cloud provider.
+	HasInstance(node *v1.Node) (bool, error)
+	// GetAvailableMachineTypes returns the instance types node groups can use.
+	GetAvailableMachineTypes() ([]string, error)
+	// GPULabel returns the label set on nodes with GPUs.
+	GPULabel() string
+	// GetAvailableGPUTypes returns the GPU types of the node groups.
+	GetAvailableGPUTypes() map[string]struct{}
+	// Cleanup releases the resources of the cloud provider.
+	Cleanup() error
+	// Refresh reloads the node groups, it is called before every autoscaler loop.
+	Refresh() error
+}
+
+// NodeGroup is adapted to the cluster-autoscaler cloudprovider.NodeGroup interface.
+type NodeGroup interface {
+	// MaxSize and MinSize bound the target size of the node group.
+	MaxSize() int
+	MinSize() int
+	// TargetSize returns the number of instances the node group should have.
+	TargetSize() (int, error)
+	// IncreaseSize adds delta instances to the target size.
+	IncreaseSize(delta int) error
+	// AtomicIncreaseSize adds delta instances only if all of them can be created.
+	AtomicIncreaseSize(delta int) error
+	// DeleteNodes deletes the instances of the nodes and lowers the target size.
+	DeleteNodes(nodes []*v1.Node) error
+	// ForceDeleteNodes deletes the instances of the nodes even below the minimum size.
+	ForceDeleteNodes(nodes []*v1.Node) error
+	// DecreaseTargetSize lowers the target size without deleting existing instances.
+	DecreaseTargetSize(delta int) error
+	// Id returns the node group ID.
+	Id() string //nolint:revive,stylecheck // named as in the cluster-autoscaler interface
+	// Debug returns a description of the node group for logs.
+	Debug() string
+	// Nodes returns the instances of the node group.
+	Nodes() ([]Instance, error)
+	// TemplateNode returns the node a new instance of the node group would register.
+	TemplateNode() (*v1.Node, error)
+	// Exist reports whether the node group exists in the cloud provider.
+	Exist() bool
+	// Create creates an autoprovisioned node group.
+	Create() (NodeGroup, error)
+	// Delete deletes an autoprovisioned node group.
+	Delete() error
+	// Autoprovisioned reports whether the node group was created by the autoscaler.
+	Autoprovisioned() bool
+}
+
+// Instance is an instance of a node group.
+type Instance struct {
+	// Id is the provider ID of the instance, as on its node.
+	Id     string //nolint:revive,stylecheck // named as in the cluster-autoscaler type
+	Status *InstanceStatus
+}
+
+// InstanceStatus is the status of an instance.
+type InstanceStatus struct {
+	State InstanceState
+}
+
+// InstanceState is the state of an instance.
+type InstanceState int
+
+const (
+	// InstanceRunning means the instance is running.
+	InstanceRunning InstanceState = iota + 1
+	// InstanceCreating means the instance is being created.
+	InstanceCreating
+	// InstanceDeleting means the instance is being deleted.
+	InstanceDeleting
+)
@@ -0,0 +1,116 @@
+package autoscaler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/pkg/autoscaler"
+)
+
+const testProjectID = "project"
+
+// fakeAPI is an in-memory Crusoe API serving the instance group, instance template,
+// VM type and instance endpoints used by the autoscaler.
+type fakeAPI struct {
+	mu        sync.Mutex
+	groups    []crusoeapi.InstanceGroup
+	templates []crusoeapi.InstanceTemplate
+	vmTypes   []crusoeapi.ModelType
+	deleted   []string
+	// failDelete are the instances whose deletion fails.
+	failDelete []string
+}
+
+// newFakeAPI serves the fake API and returns the config to reach it.
+func newFakeAPI(t *testing.T, api *fakeAPI) autoscaler.Config {
+	t.Helper()
+	t.Setenv(client.CrusoeProjectID, testProjectID)
+
+	server := httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(server.Close)
+
+	return autoscaler.Config{Endpoint: server.URL, AccessKey: "key", SecretKey: "c2VjcmV0"}
+}
+
+func (a *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/projects/"+testProjectID+"/compute/")
+	resource, id, _ := strings.Cut(path, "/")
+	switch {
+	case resource == "instance-groups" && id == "":
+		a.respond(w, crusoeapi.ListInstanceGroupsResponse{Items: a.groups})
+	case resource == "instance-groups" && r.Method == http.MethodPatch:
+		var patch crusoeapi.InstanceGroupPatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+		index := slices.IndexFunc(a.groups, func(group crusoeapi.InstanceGroup) bool { return group.Id == id })
+		if index < 0 {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+		a.groups[index].DesiredCount = patch.DesiredCount.Value
+		a.respond(w, a.groups[index])
+	case resource == "instance-templates":
+		index := slices.IndexFunc(a.templates, func(template crusoeapi.InstanceTemplate) bool {
+			return template.Id == id
+		})
+		if index < 0 {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+		a.respond(w, a.templates[index])
+	case path == "vms/types":
+		a.respond(w, crusoeapi.ListTypesResponseV1Alpha5{Items: a.vmTypes})
+	case path == "vms/instances":
+		var instances []crusoeapi.InstanceV1Alpha5
+		for _, group := range a.groups {
+			if slices.Contains(group.ActiveInstances, r.URL.Query().Get("ids")) {
+				instances = append(instances, crusoeapi.InstanceV1Alpha5{Id: r.URL.Query().Get("ids")})
+			}
+		}
+		a.respond(w, crusoeapi.ListInstancesResponseV1Alpha5{Items: instances})
+	case strings.HasPrefix(path, "vms/instances/") && r.Method == http.MethodDelete:
+		instanceID := strings.TrimPrefix(path, "vms/instances/")
+		if slices.Contains(a.failDelete, instanceID) {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+		a.deleted = append(a.deleted, instanceID)
+		for index := range a.groups {
+			a.groups[index].ActiveInstances = slices.DeleteFunc(a.groups[index].ActiveInstances,
+				func(id string) bool { return id == instanceID })
+		}
+		a.respond(w, crusoeapi.AsyncOperationResponse{})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (a *fakeAPI) respond(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (a *fakeAPI) group(id string) crusoeapi.InstanceGroup {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	index := slices.IndexFunc(a.groups, func(group crusoeapi.InstanceGroup) bool { return group.Id == id })
+
+	return a.groups[index]
+}
@@ -0,0 +1,219 @@
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+var (
+	ErrInvalidDelta       = errors.New("invalid size delta")
+	ErrSizeLimit          = errors.New("size limit of the node group reached")
+	ErrNodeNotInNodeGroup = errors.New("node does not belong to the node group")
+)
+
+// nodeGroup is an instance group autoscaled within the bounds of its spec. The
+// instance group is the one loaded at the last Refresh, updated with the resizes.
+type nodeGroup struct {
+	provider *cloudProvider
+	spec     NodeGroupSpec
+	group    crusoeapi.InstanceGroup
+}
+
+func (g *nodeGroup) MaxSize() int { return g.spec.MaxSize }
+
+func (g *nodeGroup) MinSize() int { return g.spec.MinSize }
+
+func (g *nodeGroup) TargetSize() (int, error) {
+	g.provider.mu.Lock()
+	defer g.provider.mu.Unlock()
+
+	return int(g.group.DesiredCount), nil
+}
+
+func (g *nodeGroup) IncreaseSize(delta int) error {
+	if delta <= 0 {
+		return fmt.Errorf("%w: size increase must be positive", ErrInvalidDelta)
+	}
+	target, err := g.TargetSize()
+	if err != nil {
+		return err
+	}
+	if target+delta > g.spec.MaxSize {
+		return fmt.Errorf("%w: size increase to %d is above the maximum size %d", ErrSizeLimit, target+delta,
+			g.spec.MaxSize)
+	}
+
+	return g.setTargetSize(target + delta)
+}
+
+func (g *nodeGroup) AtomicIncreaseSize(int) error { return ErrNotImplemented }
+
+func (g *nodeGroup) DeleteNodes(nodes []*v1.Node) error {
+	target, err := g.TargetSize()
+	if err != nil {
+		return err
+	}
+	if target-len(nodes) < g.spec.MinSize {
+		return fmt.Errorf("%w: deleting %d nodes goes below the minimum size %d", ErrSizeLimit, len(nodes),
+			g.spec.MinSize)
+	}
+
+	return g.ForceDeleteNodes(nodes)
+}
+
+// ForceDeleteNodes deletes the instances of the nodes one at a time, lowering the target
+// size after each deletion. Lowering it first would let the instance group remove another
+// instance to meet the target if the deletion failed. The whole batch is checked before
+// anything is deleted, as the target size cannot be lowered to zero.
+func (g *nodeGroup) ForceDeleteNodes(nodes []*v1.Node) error {
+	for _, node := range nodes {
+		if !slices.Contains(g.instanceIDs(), instanceID(node)) {
+			return fmt.Errorf("%w: node %s, node group %s", ErrNodeNotInNodeGroup, node.Name, g.Id())
+		}
+	}
+	target, err := g.TargetSize()
+	if err != nil {
+		return err
+	}
+	if target-len(nodes) < 1 {
+		return fmt.Errorf("%w: deleting %d nodes leaves no instance in the node group", ErrSizeLimit, len(nodes))
+	}
+
+	for _, node := range nodes {
+		ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+		err := g.provider.apiClient.DeleteInstance(ctx, instanceID(node))
+		cancel()
+		if err != nil && !errors.Is(err, client.ErrInstanceNotFound) {
+			return fmt.Errorf("failed to delete instance of node %s: %w", node.Name, err)
+		}
+		klog.InfoS("Deleted instance of node", "node", klog.KObj(node), "instanceGroup", g.Id())
+
+		target, err := g.TargetSize()
+		if err != nil {
+			return err
+		}
+		if err := g.setTargetSize(target - 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DecreaseTargetSize lowers the target size to cancel instances that have not been
+// created yet, it never goes below the number of existing instances.
+func (g *nodeGroup) DecreaseTargetSize(delta int) error {
+	if delta >= 0 {
+		return fmt.Errorf("%w: size decrease must be negative", ErrInvalidDelta)
+	}
+	target, err := g.TargetSize()
+	if err != nil {
+		return err
+	}
+	if existing := len(g.instanceIDs()); target+delta < existing {
+		return fmt.Errorf("%w: size decrease to %d is below the %d existing instances", ErrInvalidDelta,
+			target+delta, existing)
+	}
+
+	return g.setTargetSize(target + delta)
+}
+
+func (g *nodeGroup) Id() string { return g.group.Id } //nolint:revive,stylecheck // named as in the interface
+
+func (g *nodeGroup) Debug() string {
+	target, _ := g.TargetSize()
+
+	return fmt.Sprintf("%s (%s): min %d, max %d, target %d", g.group.Id, g.group.Name, g.spec.MinSize,
+		g.spec.MaxSize, target)
+}
+
+func (g *nodeGroup) Nodes() ([]Instance, error) {
+	g.provider.mu.Lock()
+	defer g.provider.mu.Unlock()
+
+	running, inactive := g.runningInstanceIDs(), g.group.InactiveInstances
+	instances := make([]Instance, 0, len(running)+len(inactive))
+	for _, id := range running {
+		instances = append(instances, Instance{
+			Id:     providerPrefix + id,
+			Status: &InstanceStatus{State: InstanceRunning},
+		})
+	}
+	for _, id := range inactive {
+		instances = append(instances, Instance{
+			Id:     providerPrefix + id,
+			Status: &InstanceStatus{State: InstanceCreating},
+		})
+	}
+
+	return instances, nil
+}
+
+func (g *nodeGroup) TemplateNode() (*v1.Node, error) {
+	g.provider.mu.Lock()
+	templateID := g.group.TemplateId
+	template, ok := g.provider.templates[templateID]
+	g.provider.mu.Unlock()
+	if !ok {
+		var err error
+		if template, err = g.provider.buildTemplateNode(templateID); err != nil {
+			return nil, err
+		}
+		g.provider.mu.Lock()
+		g.provider.templates[templateID] = template
+		g.provider.mu.Unlock()
+	}
+
+	node := template.DeepCopy()
+	node.Name = "template-node-for-" + g.Id()
+	node.Labels[v1.LabelHostname] = node.Name
+	g.provider.setInstanceGroupLabel(node, g.Id())
+
+	return node, nil
+}
+
+func (g *nodeGroup) Exist() bool { return true }
+
+func (g *nodeGroup) Create() (NodeGroup, error) { return nil, ErrNotImplemented }
+
+func (g *nodeGroup) Delete() error { return ErrNotImplemented }
+
+func (g *nodeGroup) Autoprovisioned() bool { return false }
+
+func (g *nodeGroup) setTargetSize(size int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	group, err := g.provider.apiClient.SetInstanceGroupDesiredCount(ctx, g.Id(), int64(size))
+	if err != nil {
+		return fmt.Errorf("failed to resize instance group %s to %d: %w", g.Id(), size, err)
+	}
+	klog.InfoS("Resized instance group", "instanceGroup", g.Id(), "targetSize", size)
+
+	g.provider.mu.Lock()
+	defer g.provider.mu.Unlock()
+	g.group.DesiredCount = group.DesiredCount
+
+	return nil
+}
+
+// runningInstanceIDs returns the running instances, older API versions only return
+// the deprecated list.
+func (g *nodeGroup) runningInstanceIDs() []string {
+	if len(g.group.ActiveInstances) == 0 {
+		return g.group.Instances
+	}
+
+	return g.group.ActiveInstances
+}
+
+func (g *nodeGroup) instanceIDs() []string {
+	return slices.Concat(g.runningInstanceIDs(), g.group.InactiveInstances)
+}
@@ -0,0 +1,225 @@
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/auth"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/client"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ProviderName is the name of the Crusoe cloud provider.
+	ProviderName = "crusoe"
+	// GPULabel is set on GPU nodes by NVIDIA GPU feature discovery, and on template
+	// nodes of instance types with GPUs.
+	GPULabel = "nvidia.com/gpu.product"
+
+	providerPrefix   = "crusoe://"
+	apiTimeout       = time.Minute
+	defaultUserAgent = "crusoe-cluster-autoscaler"
+)
+
+// cloudProvider implements CloudProvider for the instance groups selected by the specs.
+type cloudProvider struct {
+	apiClient client.APIClient
+	specs     []NodeGroupSpec
+	labels    LabelConfig
+
+	mu              sync.Mutex
+	nodeGroups      []*nodeGroup
+	instanceToGroup map[string]*nodeGroup
+	// templates caches the template nodes by instance template ID.
+	templates map[string]*v1.Node
+}
+
+// Config holds the Crusoe API endpoint and credentials.
+type Config struct {
+	// Endpoint is the base URL of the Crusoe API.
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	// UserAgent defaults to crusoe-cluster-autoscaler.
+	UserAgent string
+	// Labels must match the label config of the cloud controller manager, so that template
+	// nodes carry the same labels as the nodes it registers.
+	Labels LabelConfig
+}
+
+// LabelConfig is the label config of the cloud controller manager.
+type LabelConfig = instances.LabelConfig
+
+// NewCloudProvider returns a CloudProvider autoscaling the instance groups of the specs
+// through the Crusoe API. The instance groups are loaded before it is returned.
+func NewCloudProvider(config Config, specs []NodeGroupSpec) (CloudProvider, error) {
+	cfg := crusoeapi.NewConfiguration()
+	cfg.BasePath = config.Endpoint
+	cfg.UserAgent = config.UserAgent
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = defaultUserAgent
+	}
+	// unlike auth.NewCrusoeClient, this does not wrap http.DefaultClient, which the
+	// cluster-autoscaler uses for its own requests
+	cfg.HTTPClient = &http.Client{Transport: auth.NewAuthenticatingTransport(nil, config.AccessKey, config.SecretKey)}
+
+	return newCloudProvider(&client.APIClientImpl{CrusoeAPIClient: crusoeapi.NewAPIClient(cfg)}, specs, config.Labels)
+}
+
+func newCloudProvider(apiClient client.APIClient, specs []NodeGroupSpec, labels LabelConfig,
+) (CloudProvider, error) {
+	for index := range specs {
+		if err := specs[index].validate(); err != nil {
+			return nil, err
+		}
+	}
+	// the label config is checked like the cloud config of the cloud controller manager
+	instancesConfig := instances.Config{Labels: labels}
+	instancesConfig.ApplyDefaults()
+	if err := instancesConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid label config: %w", err)
+	}
+	provider := &cloudProvider{
+		apiClient: apiClient,
+		specs:     specs,
+		labels:    instancesConfig.Labels,
+		templates: make(map[string]*v1.Node),
+	}
+	if err := provider.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+func (p *cloudProvider) Name() string { return ProviderName }
+
+func (p *cloudProvider) NodeGroups() []NodeGroup {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	nodeGroups := make([]NodeGroup, 0, len(p.nodeGroups))
+	for _, group := range p.nodeGroups {
+		nodeGroups = append(nodeGroups, group)
+	}
+
+	return nodeGroups
+}
+
+func (p *cloudProvider) NodeGroupForNode(node *v1.Node) (NodeGroup, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	group, ok := p.instanceToGroup[instanceID(node)]
+	if !ok {
+		// the node is not autoscaled, which the cluster-autoscaler expects as a nil group
+		//nolint:nilnil // as in the cluster-autoscaler interface
+		return nil, nil
+	}
+
+	return group, nil
+}
+
+func (p *cloudProvider) HasInstance(node *v1.Node) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	_, response, err := p.apiClient.GetInstanceByID(ctx, instanceID(node))
+	if response != nil {
+		defer response.Body.Close()
+	}
+	if errors.Is(err, client.ErrInstanceNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get instance of node %s: %w", node.Name, err)
+	}
+
+	return true, nil
+}
+
+// GetAvailableMachineTypes returns the instance types of the template nodes built so far.
+func (p *cloudProvider) GetAvailableMachineTypes() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var machineTypes []string
+	for _, template := range p.templates {
+		instanceType := template.Labels[v1.LabelInstanceTypeStable]
+		if !slices.Contains(machineTypes, instanceType) {
+			machineTypes = append(machineTypes, instanceType)
+		}
+	}
+	slices.Sort(machineTypes)
+
+	return machineTypes, nil
+}
+
+func (p *cloudProvider) GPULabel() string { return GPULabel }
+
+// GetAvailableGPUTypes returns the GPU types of the template nodes built so far.
+func (p *cloudProvider) GetAvailableGPUTypes() map[string]struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	gpuTypes := make(map[string]struct{})
+	for _, template := range p.templates {
+		if gpuType, ok := template.Labels[GPULabel]; ok {
+			gpuTypes[gpuType] = struct{}{}
+		}
+	}
+
+	return gpuTypes
+}
+
+func (p *cloudProvider) Cleanup() error { return nil }
+
+// Refresh reloads the instance groups of the specs. Specs whose instance group does
+// not exist are logged and left out until it is created.
+func (p *cloudProvider) Refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	groups, err := p.apiClient.ListInstanceGroups(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list instance groups: %w", err)
+	}
+
+	nodeGroups := make([]*nodeGroup, 0, len(p.specs))
+	instanceToGroup := make(map[string]*nodeGroup)
+	for _, spec := range p.specs {
+		index := slices.IndexFunc(groups, func(group crusoeapi.InstanceGroup) bool {
+			return group.Id == spec.Group || group.Name == spec.Group
+		})
+		if index < 0 {
+			klog.InfoS("Instance group of node group spec not found", "instanceGroup", spec.Group)
+
+			continue
+		}
+		group := &nodeGroup{provider: p, spec: spec, group: groups[index]}
+		nodeGroups = append(nodeGroups, group)
+		for _, instanceID := range group.instanceIDs() {
+			instanceToGroup[instanceID] = group
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nodeGroups = nodeGroups
+	p.instanceToGroup = instanceToGroup
+
+	return nil
+}
+
+func instanceID(node *v1.Node) string {
+	return strings.TrimPrefix(node.Spec.ProviderID, providerPrefix)
+}
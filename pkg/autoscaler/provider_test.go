@@ -0,0 +1,220 @@
+package autoscaler_test
+
+import (
+	"testing"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/pkg/autoscaler"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testAPI() *fakeAPI {
+	return &fakeAPI{
+		groups: []crusoeapi.InstanceGroup{
+			{
+				Id:                "group-a",
+				Name:              "gpu-a",
+				TemplateId:        "template-a",
+				DesiredCount:      3,
+				ActiveInstances:   []string{"a1", "a2"},
+				InactiveInstances: []string{"a3"},
+			},
+			{
+				Id:              "group-b",
+				Name:            "cpu-b",
+				DesiredCount:    1,
+				ActiveInstances: []string{"b1"},
+			},
+		},
+		templates: []crusoeapi.InstanceTemplate{{
+			Id:        "template-a",
+			ProjectId: testProjectID,
+			Type_:     "h100-80gb-sxm-ib.8x",
+			Location:  "us-east1-a",
+		}},
+		vmTypes: []crusoeapi.ModelType{{
+			ProductName: "h100-80gb-sxm-ib.8x",
+			CpuCores:    176,
+			CpuType:     "Intel Xeon",
+			MemoryGb:    1440,
+			DiskGb:      7680,
+			GpuType:     "H100-SXM-80GB",
+			NumGpu:      8,
+		}},
+	}
+}
+
+func providerNode(instanceID string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-" + instanceID},
+		Spec:       v1.NodeSpec{ProviderID: "crusoe://" + instanceID},
+	}
+}
+
+func newProvider(t *testing.T, api *fakeAPI) autoscaler.CloudProvider {
+	t.Helper()
+
+	provider, err := autoscaler.NewCloudProvider(newFakeAPI(t, api), []autoscaler.NodeGroupSpec{
+		{Group: "gpu-a", MinSize: 1, MaxSize: 5},
+		{Group: "missing", MinSize: 1, MaxSize: 5},
+	})
+	require.NoError(t, err)
+
+	return provider
+}
+
+func TestParseNodeGroupSpec(t *testing.T) {
+	t.Parallel()
+
+	spec, err := autoscaler.ParseNodeGroupSpec("1:10:gpu-a")
+	require.NoError(t, err)
+	require.Equal(t, autoscaler.NodeGroupSpec{Group: "gpu-a", MinSize: 1, MaxSize: 10}, spec)
+
+	for _, value := range []string{"1:10", "a:10:gpu-a", "1:b:gpu-a", "0:10:gpu-a", "5:1:gpu-a", "1:10:"} {
+		_, err := autoscaler.ParseNodeGroupSpec(value)
+		require.ErrorIs(t, err, autoscaler.ErrInvalidNodeGroupSpec, value)
+	}
+}
+
+//nolint:paralleltest // sets CRUSOE_PROJECT_ID
+func TestNodeGroups(t *testing.T) {
+	provider := newProvider(t, testAPI())
+
+	nodeGroups := provider.NodeGroups()
+	require.Len(t, nodeGroups, 1)
+	nodeGroup := nodeGroups[0]
+	require.Equal(t, "group-a", nodeGroup.Id())
+	require.Equal(t, 1, nodeGroup.MinSize())
+	require.Equal(t, 5, nodeGroup.MaxSize())
+	targetSize, err := nodeGroup.TargetSize()
+	require.NoError(t, err)
+	require.Equal(t, 3, targetSize)
+
+	instances, err := nodeGroup.Nodes()
+	require.NoError(t, err)
+	require.Equal(t, []autoscaler.Instance{
+		{Id: "crusoe://a1", Status: &autoscaler.InstanceStatus{State: autoscaler.InstanceRunning}},
+		{Id: "crusoe://a2", Status: &autoscaler.InstanceStatus{State: autoscaler.InstanceRunning}},
+		{Id: "crusoe://a3", Status: &autoscaler.InstanceStatus{State: autoscaler.InstanceCreating}},
+	}, instances)
+
+	group, err := provider.NodeGroupForNode(providerNode("a1"))
+	require.NoError(t, err)
+	require.Equal(t, "group-a", group.Id())
+	group, err = provider.NodeGroupForNode(providerNode("b1"))
+	require.NoError(t, err)
+	require.Nil(t, group)
+
+	exists, err := provider.HasInstance(providerNode("a1"))
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = provider.HasInstance(providerNode("gone"))
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+//nolint:paralleltest // sets CRUSOE_PROJECT_ID
+func TestResize(t *testing.T) {
+	api := testAPI()
+	nodeGroup := newProvider(t, api).NodeGroups()[0]
+
+	require.NoError(t, nodeGroup.IncreaseSize(2))
+	require.Equal(t, int64(5), api.group("group-a").DesiredCount)
+	require.ErrorIs(t, nodeGroup.IncreaseSize(1), autoscaler.ErrSizeLimit)
+	require.ErrorIs(t, nodeGroup.IncreaseSize(0), autoscaler.ErrInvalidDelta)
+
+	// the target size cannot drop below the 3 existing instances
+	require.ErrorIs(t, nodeGroup.DecreaseTargetSize(-3), autoscaler.ErrInvalidDelta)
+	require.NoError(t, nodeGroup.DecreaseTargetSize(-2))
+	require.Equal(t, int64(3), api.group("group-a").DesiredCount)
+
+	require.NoError(t, nodeGroup.DeleteNodes([]*v1.Node{providerNode("a1"), providerNode("a2")}))
+	require.Equal(t, int64(1), api.group("group-a").DesiredCount)
+	require.Equal(t, []string{"a1", "a2"}, api.deleted)
+
+	require.ErrorIs(t, nodeGroup.DeleteNodes([]*v1.Node{providerNode("a3")}), autoscaler.ErrSizeLimit)
+	require.ErrorIs(t, nodeGroup.ForceDeleteNodes([]*v1.Node{providerNode("b1")}), autoscaler.ErrNodeNotInNodeGroup)
+}
+
+//nolint:paralleltest // sets CRUSOE_PROJECT_ID
+func TestForceDeleteNodes(t *testing.T) {
+	api := testAPI()
+	api.failDelete = []string{"a2"}
+	nodeGroup := newProvider(t, api).NodeGroups()[0]
+
+	// deleting every instance would need a target size of zero, so nothing is deleted
+	require.ErrorIs(t, nodeGroup.ForceDeleteNodes([]*v1.Node{providerNode("a1"), providerNode("a2"),
+		providerNode("a3")}), autoscaler.ErrSizeLimit)
+	require.Empty(t, api.deleted)
+	require.Equal(t, int64(3), api.group("group-a").DesiredCount)
+
+	// the target size is only lowered for the instances that were deleted
+	require.Error(t, nodeGroup.ForceDeleteNodes([]*v1.Node{providerNode("a1"), providerNode("a2")}))
+	require.Equal(t, []string{"a1"}, api.deleted)
+	require.Equal(t, int64(2), api.group("group-a").DesiredCount)
+	targetSize, err := nodeGroup.TargetSize()
+	require.NoError(t, err)
+	require.Equal(t, 2, targetSize)
+}
+
+//nolint:paralleltest // sets CRUSOE_PROJECT_ID
+func TestTemplateNode(t *testing.T) {
+	provider := newProvider(t, testAPI())
+
+	node, err := provider.NodeGroups()[0].TemplateNode()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		v1.LabelOSStable:                 "linux",
+		v1.LabelArchStable:               "amd64",
+		v1.LabelInstanceTypeStable:       "h100-80gb-sxm-ib.8x",
+		v1.LabelTopologyRegion:           "us-east1-a",
+		v1.LabelHostname:                 "template-node-for-group-a",
+		autoscaler.GPULabel:              "H100-SXM-80GB",
+		"crusoe.ai/instance.group.id":    "group-a",
+		"crusoe.ai/instance.template.id": "template-a",
+	}, node.Labels)
+	require.Equal(t, "176", node.Status.Allocatable.Cpu().String())
+	require.Equal(t, "1440Gi", node.Status.Allocatable.Memory().String())
+	require.Equal(t, "8", node.Status.Allocatable.Name("nvidia.com/gpu", resource.DecimalSI).String())
+
+	machineTypes, err := provider.GetAvailableMachineTypes()
+	require.NoError(t, err)
+	require.Equal(t, []string{"h100-80gb-sxm-ib.8x"}, machineTypes)
+	require.Equal(t, map[string]struct{}{"H100-SXM-80GB": {}}, provider.GetAvailableGPUTypes())
+}
+
+//nolint:paralleltest // sets CRUSOE_PROJECT_ID
+func TestTemplateNodeLabelConfig(t *testing.T) {
+	config := newFakeAPI(t, testAPI())
+	config.Labels = autoscaler.LabelConfig{Prefix: "example.com"}
+	provider, err := autoscaler.NewCloudProvider(config, []autoscaler.NodeGroupSpec{
+		{Group: "gpu-a", MinSize: 1, MaxSize: 5},
+	})
+	require.NoError(t, err)
+
+	// the template node is labeled like the cloud controller manager labels the nodes
+	node, err := provider.NodeGroups()[0].TemplateNode()
+	require.NoError(t, err)
+	require.Equal(t, "group-a", node.Labels["example.com/instance.group.id"])
+	require.Equal(t, "template-a", node.Labels["example.com/instance.template.id"])
+	require.NotContains(t, node.Labels, "crusoe.ai/instance.group.id")
+
+	// no instance labels are set when the instance label group is disabled
+	config.Labels = autoscaler.LabelConfig{Groups: []string{"ib"}}
+	provider, err = autoscaler.NewCloudProvider(config, []autoscaler.NodeGroupSpec{
+		{Group: "gpu-a", MinSize: 1, MaxSize: 5},
+	})
+	require.NoError(t, err)
+	node, err = provider.NodeGroups()[0].TemplateNode()
+	require.NoError(t, err)
+	require.NotContains(t, node.Labels, "crusoe.ai/instance.group.id")
+	require.NotContains(t, node.Labels, "crusoe.ai/instance.template.id")
+
+	config.Labels = autoscaler.LabelConfig{Prefix: "not a prefix"}
+	_, err = autoscaler.NewCloudProvider(config, nil)
+	require.ErrorIs(t, err, instances.ErrInvalidLabelPrefix)
+}
@@ -0,0 +1,50 @@
+package autoscaler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidNodeGroupSpec = errors.New("invalid node group spec")
+
+// NodeGroupSpec selects an instance group to autoscale.
+type NodeGroupSpec struct {
+	// Group is the ID or name of the instance group.
+	Group   string
+	MinSize int
+	MaxSize int
+}
+
+// ParseNodeGroupSpec parses a spec in the <min>:<max>:<group> format of the
+// cluster-autoscaler --nodes flag. The minimum size is at least 1, the Crusoe API
+// client cannot resize instance groups to zero.
+func ParseNodeGroupSpec(value string) (NodeGroupSpec, error) {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return NodeGroupSpec{}, fmt.Errorf("%w %q: expected <min>:<max>:<group>", ErrInvalidNodeGroupSpec, value)
+	}
+	minSize, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return NodeGroupSpec{}, fmt.Errorf("%w %q: minimum size: %w", ErrInvalidNodeGroupSpec, value, err)
+	}
+	maxSize, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return NodeGroupSpec{}, fmt.Errorf("%w %q: maximum size: %w", ErrInvalidNodeGroupSpec, value, err)
+	}
+	spec := NodeGroupSpec{Group: parts[2], MinSize: minSize, MaxSize: maxSize}
+
+	return spec, spec.validate()
+}
+
+func (s *NodeGroupSpec) validate() error {
+	if s.MinSize < 1 {
+		return fmt.Errorf("%w %s: the minimum size must be at least 1", ErrInvalidNodeGroupSpec, s.Group)
+	}
+	if s.MaxSize < s.MinSize {
+		return fmt.Errorf("%w %s: the maximum size is below the minimum size", ErrInvalidNodeGroupSpec, s.Group)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-cloud-controller-manager/internal/instances"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	resourceNvidiaGPU v1.ResourceName = "nvidia.com/gpu"
+	defaultMaxPods                    = 110
+	gibibyte                          = 1 << 30
+)
+
+// buildTemplateNode builds the node an instance created from the instance template
+// registers, from the resources and labels of its instance type.
+func (p *cloudProvider) buildTemplateNode(templateID string) (*v1.Node, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	template, err := p.apiClient.GetInstanceTemplate(ctx, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance template %s: %w", templateID, err)
+	}
+	vmType, err := p.apiClient.GetVMType(ctx, template.ProjectId, template.Type_)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vm type %s: %w", template.Type_, err)
+	}
+
+	return templateNode(template, vmType, &p.labels), nil
+}
+
+func templateNode(template *crusoeapi.InstanceTemplate, vmType *crusoeapi.ModelType, labels *LabelConfig,
+) *v1.Node {
+	capacity := v1.ResourceList{
+		v1.ResourceCPU:    *resource.NewQuantity(vmType.CpuCores, resource.DecimalSI),
+		v1.ResourceMemory: *resource.NewQuantity(vmType.MemoryGb*gibibyte, resource.BinarySI),
+		v1.ResourcePods:   *resource.NewQuantity(defaultMaxPods, resource.DecimalSI),
+	}
+	if vmType.DiskGb > 0 {
+		capacity[v1.ResourceEphemeralStorage] = *resource.NewQuantity(vmType.DiskGb*gibibyte, resource.BinarySI)
+	}
+
+	nodeLabels := map[string]string{
+		v1.LabelOSStable:           "linux",
+		v1.LabelArchStable:         architecture(vmType.CpuType),
+		v1.LabelInstanceTypeStable: template.Type_,
+	}
+	if key, enabled := labels.Key(instances.LabelGroupInstance, instances.LabelInstanceTemplateID); enabled {
+		nodeLabels[key] = template.Id
+	}
+	if template.Location != "" {
+		// the cloud node controller sets the region to the instance location
+		nodeLabels[v1.LabelTopologyRegion] = template.Location
+	}
+	if vmType.NumGpu > 0 {
+		capacity[resourceNvidiaGPU] = *resource.NewQuantity(vmType.NumGpu, resource.DecimalSI)
+		nodeLabels[GPULabel] = vmType.GpuType
+	}
+
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Labels: nodeLabels},
+		Status: v1.NodeStatus{
+			Capacity:    capacity,
+			Allocatable: capacity.DeepCopy(),
+			Conditions: []v1.NodeCondition{{
+				Type:   v1.NodeReady,
+				Status: v1.ConditionTrue,
+			}},
+		},
+	}
+}
+
+// setInstanceGroupLabel labels the template node with the instance group, like the cloud
+// controller manager labels the nodes of the group.
+func (p *cloudProvider) setInstanceGroupLabel(node *v1.Node, groupID string) {
+	if key, enabled := p.labels.Key(instances.LabelGroupInstance, instances.LabelInstanceGroupID); enabled {
+		node.Labels[key] = groupID
+	}
+}
+
+// architecture guesses the node architecture from the CPU type of the instance type.
+func architecture(cpuType string) string {
+	cpuType = strings.ToLower(cpuType)
+	if strings.Contains(cpuType, "arm") || strings.Contains(cpuType, "grace") {
+		return "arm64"
+	}
+
+	return "amd64"
+}